@@ -0,0 +1,193 @@
+package chroma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AliasCollection is the reserved collection holding the aliases of collection names, one
+// document per alias
+const AliasCollection = "gochroma-aliases"
+
+// metadata key of alias documents holding the name of the aliased collection
+const aliasTarget = "collection"
+
+var (
+	// ErrAliasNotFound is returned for aliases that don't exist
+	ErrAliasNotFound = errors.New("alias not found")
+	// ErrAliasExists is returned when creating an alias that already exists
+	ErrAliasExists = errors.New("alias already exists")
+)
+
+// WithAliases makes GetCollection and GetOrCreateCollection resolve names through the aliases
+// stored in AliasCollection before falling back to the collection of that name. It costs a
+// request per lookup
+func WithAliases() ClientOption {
+	return func(c *Client) {
+		c.aliases = true
+	}
+}
+
+// CreateAlias makes alias another name of the collection called name
+func (c *Client) CreateAlias(alias, name string) error {
+	ctx := context.Background()
+	if _, ok, err := c.lookupAlias(ctx, alias); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("%w: %s", ErrAliasExists, alias)
+	}
+	return c.pointAlias(ctx, alias, name)
+}
+
+// UpdateAlias points an existing alias to the collection called name
+func (c *Client) UpdateAlias(alias, name string) error {
+	ctx := context.Background()
+	if _, err := c.resolveAlias(ctx, alias); err != nil {
+		return err
+	}
+	return c.pointAlias(ctx, alias, name)
+}
+
+// DeleteAlias deletes alias, leaving the collection it points to as it is
+func (c *Client) DeleteAlias(alias string) error {
+	ctx := context.Background()
+	if _, err := c.resolveAlias(ctx, alias); err != nil {
+		return err
+	}
+	_, err := c.withRegistry(false, func(registry Collection) error {
+		return registry.DeleteContext(ctx, []string{alias}, nil, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting alias %s: %w", alias, err)
+	}
+	return nil
+}
+
+// ResolveAlias returns the name of the collection alias points to
+func (c *Client) ResolveAlias(alias string) (string, error) {
+	return c.resolveAlias(context.Background(), alias)
+}
+
+// resolveAlias is lookupAlias failing with ErrAliasNotFound for missing aliases
+func (c *Client) resolveAlias(ctx context.Context, alias string) (string, error) {
+	name, ok, err := c.lookupAlias(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrAliasNotFound, alias)
+	}
+	return name, nil
+}
+
+// pointAlias points alias to the collection called name after checking that it exists
+func (c *Client) pointAlias(ctx context.Context, alias, name string) error {
+	if alias == AliasCollection {
+		return fmt.Errorf("%s is reserved for aliases", AliasCollection)
+	}
+	if _, err := c.getCollection(name, nil); err != nil {
+		return err
+	}
+	return c.setAlias(ctx, alias, name)
+}
+
+// alias returns the collection name aliased by name on clients created WithAliases, else name
+func (c *Client) alias(name string) (string, error) {
+	if !c.aliases || name == AliasCollection {
+		return name, nil
+	}
+	target, ok, err := c.lookupAlias(context.Background(), name)
+	if err != nil || !ok {
+		return name, err
+	}
+	return target, nil
+}
+
+// aliasRegistry returns the collection holding aliases, nil when there is none and create is false
+func (c *Client) aliasRegistry(create bool) (*Collection, error) {
+	c.registryMu.Lock()
+	defer c.registryMu.Unlock()
+	if c.registry != nil {
+		return c.registry, nil
+	}
+	var registry Collection
+	var err error
+	if create {
+		registry, err = c.createCollection(AliasCollection, "l2", nil, true, nil)
+	} else {
+		registry, err = c.getCollection(AliasCollection, nil)
+		if isNotFound(err) {
+			// no alias was ever set, reads don't create the registry
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting alias registry: %w", err)
+	}
+	c.registry = &registry
+	return c.registry, nil
+}
+
+// withRegistry calls fn with the alias registry. A cached registry deleted since, like by
+// another client resetting the server, is fetched again once. It returns false without calling
+// fn when there is no registry and create is false
+func (c *Client) withRegistry(create bool, fn func(registry Collection) error) (bool, error) {
+	for retried := false; ; retried = true {
+		registry, err := c.aliasRegistry(create)
+		if err != nil || registry == nil {
+			return false, err
+		}
+		err = fn(*registry)
+		if retried || !isNotFound(err) {
+			return true, err
+		}
+		c.registryMu.Lock()
+		if c.registry != nil && c.registry.ID == registry.ID {
+			c.registry = nil
+		}
+		c.registryMu.Unlock()
+	}
+}
+
+// isNotFound reports whether err is chroma failing on a collection that doesn't exist
+func isNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "does not exist")
+}
+
+// lookupAlias returns the name of the collection aliased by alias, if any
+func (c *Client) lookupAlias(ctx context.Context, alias string) (string, bool, error) {
+	var docs []Document
+	found, err := c.withRegistry(false, func(registry Collection) (err error) {
+		docs, err = registry.get(ctx, map[string]any{"ids": []string{alias}, "include": []QueryEnum{WithMetadatas}})
+		return err
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("error resolving alias %s: %w", alias, err)
+	}
+	if !found || len(docs) == 0 {
+		return "", false, nil
+	}
+	name, ok := docs[0].Metadata[aliasTarget].(string)
+	if !ok {
+		return "", false, fmt.Errorf("alias %s has no collection", alias)
+	}
+	return name, true, nil
+}
+
+// setAlias points alias to the collection called name, replacing any previous target, and
+// creates the registry if needed. The change is a single document write, readers see either the
+// previous or the new target
+func (c *Client) setAlias(ctx context.Context, alias, name string) error {
+	_, err := c.withRegistry(true, func(registry Collection) error {
+		// chroma requires an embedding for every document
+		return registry.UpsertContext(ctx, []Document{{
+			ID: alias, Content: name, Metadata: map[string]any{aliasTarget: name}, Embeddings: []float32{0},
+		}}, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("error setting alias %s: %w", alias, err)
+	}
+	return nil
+}
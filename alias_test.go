@@ -0,0 +1,107 @@
+package chroma_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+var _ = Describe("Aliases", func() {
+	var server *fakeChroma
+	var client *chroma.Client
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+		c, err := chroma.NewClient(server.URL, chroma.WithAliases())
+		Expect(err).ToNot(HaveOccurred())
+		client = c.(*chroma.Client)
+		for _, name := range []string{"blue", "green"} {
+			_, err := client.CreateCollection(name, "l2", nil)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("creates, updates, resolves and deletes aliases", func() {
+		_, err := client.ResolveAlias("live")
+		Expect(err).To(MatchError(chroma.ErrAliasNotFound))
+		Expect(client.UpdateAlias("live", "green")).To(MatchError(chroma.ErrAliasNotFound))
+
+		Expect(client.CreateAlias("live", "blue")).To(Succeed())
+		Expect(client.ResolveAlias("live")).To(Equal("blue"))
+		Expect(client.CreateAlias("live", "green")).To(MatchError(chroma.ErrAliasExists))
+		Expect(server.byName(chroma.AliasCollection)).ToNot(BeNil())
+
+		Expect(client.UpdateAlias("live", "green")).To(Succeed())
+		Expect(client.ResolveAlias("live")).To(Equal("green"))
+
+		Expect(client.DeleteAlias("live")).To(Succeed())
+		_, err = client.ResolveAlias("live")
+		Expect(err).To(MatchError(chroma.ErrAliasNotFound))
+		Expect(client.DeleteAlias("live")).To(MatchError(chroma.ErrAliasNotFound))
+		Expect(server.byName("green")).ToNot(BeNil())
+	})
+
+	It("only aliases existing collections", func() {
+		Expect(client.CreateAlias("live", "red")).To(MatchError(ContainSubstring("does not exist")))
+		Expect(client.CreateAlias(chroma.AliasCollection, "blue")).To(HaveOccurred())
+	})
+
+	It("resolves aliases when getting collections", func() {
+		Expect(client.CreateAlias("live", "blue")).To(Succeed())
+		collection, err := client.GetCollection("live")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Name).To(Equal("blue"))
+
+		collection, err = client.GetOrCreateCollection("live", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Name).To(Equal("blue"))
+		Expect(server.byName("live")).To(BeNil())
+
+		collection, err = client.GetCollection("green")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Name).To(Equal("green"))
+	})
+
+	It("doesn't create the alias registry when reading", func() {
+		_, err := client.GetCollection("blue")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = client.GetOrCreateCollection("green", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = client.ResolveAlias("live")
+		Expect(err).To(MatchError(chroma.ErrAliasNotFound))
+		Expect(server.byName(chroma.AliasCollection)).To(BeNil())
+
+		collections, err := client.ListCollections()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collections).To(HaveLen(2))
+	})
+
+	It("fetches the alias registry again once another client deleted it", func() {
+		Expect(client.CreateAlias("live", "blue")).To(Succeed())
+		other, err := chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other.DeleteCollection(chroma.AliasCollection)).To(Succeed())
+
+		_, err = client.ResolveAlias("live")
+		Expect(err).To(MatchError(chroma.ErrAliasNotFound))
+		_, err = client.GetCollection("live")
+		Expect(err).To(MatchError(ContainSubstring("does not exist")))
+
+		Expect(client.CreateAlias("live", "green")).To(Succeed())
+		collection, err := client.GetCollection("live")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Name).To(Equal("green"))
+	})
+
+	It("doesn't resolve aliases by default", func() {
+		Expect(client.CreateAlias("live", "blue")).To(Succeed())
+		plain, err := chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = plain.GetCollection("live")
+		Expect(err).To(HaveOccurred())
+	})
+})
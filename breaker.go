@@ -0,0 +1,197 @@
+package chroma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the server while a circuit breaker is open
+var ErrCircuitOpen = errors.New("chroma circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen probes the server with a heartbeat before letting the next request through
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitBreaker fails requests fast while the server is failing. Transport errors, timeouts and
+// 5xx responses count as failures, client errors and cancelled requests don't
+type CircuitBreaker struct {
+	consecutiveFailures int
+	failureRate         float64
+	minRequests         int
+	window              time.Duration
+	openTimeout         time.Duration
+
+	mu          sync.Mutex
+	state       CircuitState // CircuitClosed or CircuitOpen, half open is derived from openedAt
+	openedAt    time.Time
+	probing     bool
+	consecutive int
+	windowStart time.Time
+	requests    int
+	failures    int
+}
+
+// BreakerOption configures a CircuitBreaker
+type BreakerOption func(*CircuitBreaker)
+
+// WithConsecutiveFailures trips the breaker after failures consecutive failed requests.
+// Defaults to 5 unless WithFailureRate is given
+func WithConsecutiveFailures(failures int) BreakerOption {
+	return func(b *CircuitBreaker) {
+		b.consecutiveFailures = failures
+	}
+}
+
+// WithFailureRate trips the breaker when at least rate of the requests in a window of the given
+// length failed, once the window saw minRequests requests
+func WithFailureRate(rate float64, minRequests int, window time.Duration) BreakerOption {
+	return func(b *CircuitBreaker) {
+		b.failureRate = rate
+		b.minRequests = minRequests
+		b.window = window
+	}
+}
+
+// WithOpenTimeout sets how long the breaker stays open before probing the server. Defaults to 30s
+func WithOpenTimeout(timeout time.Duration) BreakerOption {
+	return func(b *CircuitBreaker) {
+		b.openTimeout = timeout
+	}
+}
+
+// NewCircuitBreaker returns a closed breaker, to be given to WithCircuitBreaker. Keep it around
+// to report its State in health checks
+func NewCircuitBreaker(opts ...BreakerOption) *CircuitBreaker {
+	b := &CircuitBreaker{openTimeout: 30 * time.Second, windowStart: time.Now()}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.consecutiveFailures == 0 && b.failureRate == 0 {
+		b.consecutiveFailures = 5
+	}
+	return b
+}
+
+// WithCircuitBreaker guards every request of the client and its collections with breaker. With
+// multiple endpoints a request only counts as failed when no endpoint served it
+func WithCircuitBreaker(breaker *CircuitBreaker) ClientOption {
+	return func(c *Client) {
+		c.breaker = breaker
+	}
+}
+
+// State returns the current state of the breaker
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current(time.Now())
+}
+
+func (b *CircuitBreaker) current(now time.Time) CircuitState {
+	if b.state == CircuitOpen && now.Sub(b.openedAt) >= b.openTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow returns nil if a request may be sent. A half open breaker lets a single caller run probe
+// and closes when it succeeds, other callers fail fast meanwhile
+func (b *CircuitBreaker) allow(ctx context.Context, probe func(ctx context.Context) error) error {
+	b.mu.Lock()
+	switch b.current(time.Now()) {
+	case CircuitClosed:
+		b.mu.Unlock()
+		return nil
+	case CircuitOpen:
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+	if b.probing {
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	b.mu.Unlock()
+
+	err := probe(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		b.trip(time.Now())
+		return fmt.Errorf("%w: heartbeat probe failed: %v", ErrCircuitOpen, err)
+	}
+	b.state = CircuitClosed
+	b.consecutive = 0
+	b.resetWindow(time.Now())
+	return nil
+}
+
+// record counts the outcome of a request sent while the breaker was closed
+func (b *CircuitBreaker) record(result RequestResult) {
+	failed := result.StatusCode >= http.StatusInternalServerError ||
+		result.StatusCode == 0 && result.Err != nil && !errors.Is(result.Err, context.Canceled)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if b.state != CircuitClosed {
+		return
+	}
+	if b.window > 0 && now.Sub(b.windowStart) >= b.window {
+		b.resetWindow(now)
+	}
+	b.requests++
+	if !failed {
+		b.consecutive = 0
+		return
+	}
+	b.failures++
+	b.consecutive++
+	if b.consecutiveFailures > 0 && b.consecutive >= b.consecutiveFailures ||
+		b.failureRate > 0 && b.requests >= b.minRequests && float64(b.failures)/float64(b.requests) >= b.failureRate {
+		b.trip(now)
+	}
+}
+
+func (b *CircuitBreaker) trip(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.consecutive = 0
+	b.resetWindow(now)
+}
+
+func (b *CircuitBreaker) resetWindow(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+}
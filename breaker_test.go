@@ -0,0 +1,77 @@
+package chroma_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+var _ = Describe("Circuit breaker", func() {
+	var server *fakeChroma
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("trips after consecutive failures and probes the heartbeat once the timeout passed", func() {
+		breaker := chroma.NewCircuitBreaker(chroma.WithConsecutiveFailures(2),
+			chroma.WithOpenTimeout(50*time.Millisecond))
+		client, err := chroma.NewClient(server.URL, chroma.WithCircuitBreaker(breaker))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(breaker.State()).To(Equal(chroma.CircuitClosed))
+
+		server.SetFailing(true)
+		_, err = client.Heartbeat()
+		Expect(err).ToNot(MatchError(chroma.ErrCircuitOpen))
+		Expect(breaker.State()).To(Equal(chroma.CircuitClosed))
+		_, err = client.Heartbeat()
+		Expect(err).ToNot(MatchError(chroma.ErrCircuitOpen))
+		Expect(breaker.State()).To(Equal(chroma.CircuitOpen))
+
+		sent := len(server.Requests())
+		_, err = client.Heartbeat()
+		Expect(err).To(MatchError(chroma.ErrCircuitOpen))
+		Expect(server.Requests()).To(HaveLen(sent))
+
+		// a failed probe opens the breaker again
+		Eventually(breaker.State).Should(Equal(chroma.CircuitHalfOpen))
+		_, err = client.Heartbeat()
+		Expect(err).To(MatchError(chroma.ErrCircuitOpen))
+		Expect(server.Requests()[sent:]).To(Equal([]string{"GET /heartbeat"}))
+		Expect(breaker.State()).To(Equal(chroma.CircuitOpen))
+
+		server.SetFailing(false)
+		Eventually(breaker.State).Should(Equal(chroma.CircuitHalfOpen))
+		_, err = client.Heartbeat()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()[sent:]).To(Equal([]string{"GET /heartbeat", "GET /heartbeat", "GET /heartbeat"}))
+		Expect(breaker.State()).To(Equal(chroma.CircuitClosed))
+	})
+
+	It("trips on the failure rate within a window", func() {
+		breaker := chroma.NewCircuitBreaker(chroma.WithFailureRate(0.5, 4, time.Minute))
+		client, err := chroma.NewClient(server.URL, chroma.WithCircuitBreaker(breaker))
+		Expect(err).ToNot(HaveOccurred())
+
+		for i := 0; i < 3; i++ {
+			_, err = client.Heartbeat()
+			Expect(err).ToNot(HaveOccurred())
+		}
+		server.SetFailing(true)
+		for i := 0; i < 2; i++ {
+			_, err = client.Heartbeat()
+			Expect(err).To(HaveOccurred())
+		}
+		Expect(breaker.State()).To(Equal(chroma.CircuitClosed))
+		_, err = client.Heartbeat()
+		Expect(err).To(HaveOccurred())
+		Expect(breaker.State()).To(Equal(chroma.CircuitOpen))
+		Expect(breaker.State().String()).To(Equal("open"))
+	})
+})
@@ -0,0 +1,511 @@
+// Package chromaarrow writes collections to Apache Parquet and Arrow IPC files and reads them back.
+// Files have an id and a content column, embeddings in a fixed size list of float32 and one typed
+// column per metadata key, so they can be analysed with tools like DuckDB or pandas
+package chromaarrow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/parquet"
+	"github.com/apache/arrow/go/v15/parquet/compress"
+	"github.com/apache/arrow/go/v15/parquet/file"
+	"github.com/apache/arrow/go/v15/parquet/pqarrow"
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+// Names of the columns every file has. All other columns hold metadata
+const (
+	IDColumn        = "id"
+	ContentColumn   = "content"
+	EmbeddingColumn = "embedding"
+)
+
+// CollectionMetadataKey is the schema metadata key holding the collection's name, metadata and
+// distance function as json
+const CollectionMetadataKey = "chroma:collection"
+
+// Options configures writing and reading files
+type Options struct {
+	// BatchSize is the number of documents per request and per record batch. Defaults to 1000
+	BatchSize int
+	// Where and WhereDocument only write the matching documents
+	Where         map[string]any
+	WhereDocument map[string]any
+	// Embedder embeds the documents read without embeddings. Defaults to the collection's embedder
+	Embedder embeddings.Embedder
+}
+
+func (o Options) batchSize() int {
+	if o.BatchSize <= 0 {
+		return 1000
+	}
+	return o.BatchSize
+}
+
+// File is a file to read, like *os.File or *bytes.Reader
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// recordWriter is implemented by the arrow ipc and parquet file writers
+type recordWriter interface {
+	Write(rec arrow.Record) error
+	Close() error
+}
+
+// WriteParquet writes the documents of c to w as a snappy compressed parquet file, returning the
+// number of documents written
+func WriteParquet(ctx context.Context, c chroma.Collection, w io.Writer, opts Options) (int, error) {
+	return write(ctx, c, opts, func(schema *arrow.Schema) (recordWriter, error) {
+		props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+		// storing the arrow schema keeps the embedding column a fixed size list when read back
+		return pqarrow.NewFileWriter(schema, w, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	})
+}
+
+// WriteArrow writes the documents of c to w as an Arrow IPC file, returning the number of documents written
+func WriteArrow(ctx context.Context, c chroma.Collection, w io.Writer, opts Options) (int, error) {
+	return write(ctx, c, opts, func(schema *arrow.Schema) (recordWriter, error) {
+		return ipc.NewFileWriter(&positionWriter{w: w}, ipc.WithSchema(schema))
+	})
+}
+
+// positionWriter lets the arrow file writer, which only asks for the current position, write to
+// writers that can't seek
+type positionWriter struct {
+	w   io.Writer
+	pos int64
+}
+
+func (p *positionWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.pos += int64(n)
+	return n, err
+}
+
+func (p *positionWriter) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekCurrent {
+		return 0, errors.New("chromaarrow: writer can't seek")
+	}
+	return p.pos, nil
+}
+
+// write scans c twice: once to infer the metadata columns and once to write the documents. It
+// fails when a metadata key is added or its value no longer fits its column by the second scan
+func write(ctx context.Context, c chroma.Collection, opts Options,
+	newWriter func(schema *arrow.Schema) (recordWriter, error)) (int, error) {
+	columns := metadataColumns{}
+	scan := chroma.ScanOptions{BatchSize: opts.batchSize(), Where: opts.Where, WhereDocument: opts.WhereDocument}
+	scan.Include = []chroma.QueryEnum{chroma.WithMetadatas}
+	err := c.Scan(ctx, scan, func(docs []chroma.Document) error {
+		for _, doc := range docs {
+			if err := columns.add(doc.Metadata); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	collection, err := json.Marshal(chroma.ExportedCollection{Name: c.Name, Metadata: c.Metadata, DistanceFn: c.DistanceFn})
+	if err != nil {
+		return 0, err
+	}
+	var schema *arrow.Schema
+	var writer recordWriter
+	written := 0
+	scan.Include = nil
+	err = c.Scan(ctx, scan, func(docs []chroma.Document) error {
+		if writer == nil {
+			// the first page decides the embedding dimension, chroma stores embeddings of a single dimension
+			schema = columns.schema(dimension(docs), arrow.NewMetadata(
+				[]string{CollectionMetadataKey}, []string{string(collection)}))
+			var err error
+			if writer, err = newWriter(schema); err != nil {
+				return err
+			}
+		}
+		rec, err := buildRecord(schema, docs)
+		if err != nil {
+			return err
+		}
+		defer rec.Release()
+		if err := writer.Write(rec); err != nil {
+			return err
+		}
+		written += len(docs)
+		return nil
+	})
+	if writer == nil && err == nil {
+		// an empty collection still gets a valid file
+		schema = columns.schema(0, arrow.NewMetadata([]string{CollectionMetadataKey}, []string{string(collection)}))
+		writer, err = newWriter(schema)
+	}
+	if writer != nil {
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return written, err
+}
+
+func dimension(docs []chroma.Document) int {
+	for _, doc := range docs {
+		if len(doc.Embeddings) > 0 {
+			return len(doc.Embeddings)
+		}
+	}
+	return 0
+}
+
+// metadataColumns infers the type of every metadata key
+type metadataColumns struct {
+	names []string
+	types map[string]arrow.DataType
+}
+
+func (m *metadataColumns) add(metadata map[string]any) error {
+	if m.types == nil {
+		m.types = map[string]arrow.DataType{}
+	}
+	for key, value := range metadata {
+		if key == IDColumn || key == ContentColumn || key == EmbeddingColumn {
+			return fmt.Errorf("metadata key %q collides with a column of the same name", key)
+		}
+		t := typeOf(value)
+		if t == nil {
+			continue
+		}
+		existing, ok := m.types[key]
+		switch {
+		case !ok:
+			m.names = append(m.names, key)
+			m.types[key] = t
+		case arrow.TypeEqual(existing, t):
+		case isNumeric(existing) && isNumeric(t):
+			m.types[key] = arrow.PrimitiveTypes.Float64
+		default:
+			// mixed types are kept as text
+			m.types[key] = arrow.BinaryTypes.String
+		}
+	}
+	return nil
+}
+
+func (m *metadataColumns) schema(dimension int, metadata arrow.Metadata) *arrow.Schema {
+	fields := []arrow.Field{
+		{Name: IDColumn, Type: arrow.BinaryTypes.String},
+		{Name: ContentColumn, Type: arrow.BinaryTypes.String, Nullable: true},
+	}
+	if dimension > 0 {
+		fields = append(fields, arrow.Field{
+			Name: EmbeddingColumn, Type: arrow.FixedSizeListOf(int32(dimension), arrow.PrimitiveTypes.Float32), Nullable: true,
+		})
+	}
+	sort.Strings(m.names)
+	for _, name := range m.names {
+		fields = append(fields, arrow.Field{Name: name, Type: m.types[name], Nullable: true})
+	}
+	return arrow.NewSchema(fields, &metadata)
+}
+
+// typeOf returns the column type for a metadata value, numbers decoded from json are integers when
+// they have no fraction
+func typeOf(value any) arrow.DataType {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case bool:
+		return arrow.FixedWidthTypes.Boolean
+	case int, int32, int64:
+		return arrow.PrimitiveTypes.Int64
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return arrow.PrimitiveTypes.Int64
+		}
+		return arrow.PrimitiveTypes.Float64
+	case float32:
+		return arrow.PrimitiveTypes.Float64
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+func isNumeric(t arrow.DataType) bool {
+	return t.ID() == arrow.INT64 || t.ID() == arrow.FLOAT64
+}
+
+func buildRecord(schema *arrow.Schema, docs []chroma.Document) (arrow.Record, error) {
+	for _, doc := range docs {
+		for key, value := range doc.Metadata {
+			// keys named like a column were rejected by the first scan, so they are new too
+			reserved := key == IDColumn || key == ContentColumn || key == EmbeddingColumn
+			if value != nil && (reserved || !schema.HasField(key)) {
+				return nil, fmt.Errorf("document %s: metadata %q was added while writing", doc.ID, key)
+			}
+		}
+	}
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	for i, field := range schema.Fields() {
+		for _, doc := range docs {
+			if err := appendValue(b.Field(i), field, doc); err != nil {
+				return nil, fmt.Errorf("document %s: %w", doc.ID, err)
+			}
+		}
+	}
+	return b.NewRecord(), nil
+}
+
+func appendValue(b array.Builder, field arrow.Field, doc chroma.Document) error {
+	switch field.Name {
+	case IDColumn:
+		b.(*array.StringBuilder).Append(doc.ID)
+		return nil
+	case ContentColumn:
+		b.(*array.StringBuilder).Append(doc.Content)
+		return nil
+	case EmbeddingColumn:
+		lb := b.(*array.FixedSizeListBuilder)
+		if len(doc.Embeddings) == 0 {
+			lb.AppendNull()
+			return nil
+		}
+		if dim := int(field.Type.(*arrow.FixedSizeListType).Len()); len(doc.Embeddings) != dim {
+			return embeddings.DimensionError{Expected: dim, Got: len(doc.Embeddings)}
+		}
+		lb.Append(true)
+		lb.ValueBuilder().(*array.Float32Builder).AppendValues(doc.Embeddings, nil)
+		return nil
+	}
+
+	value, ok := doc.Metadata[field.Name]
+	if !ok || value == nil {
+		b.AppendNull()
+		return nil
+	}
+	switch b := b.(type) {
+	case *array.BooleanBuilder:
+		v, ok := value.(bool)
+		if !ok {
+			return changedTypeError(field, value)
+		}
+		b.Append(v)
+	case *array.Int64Builder:
+		switch v := value.(type) {
+		case int:
+			b.Append(int64(v))
+		case int32:
+			b.Append(int64(v))
+		case int64:
+			b.Append(v)
+		case float64:
+			if v != math.Trunc(v) {
+				return changedTypeError(field, value)
+			}
+			b.Append(int64(v))
+		default:
+			return changedTypeError(field, value)
+		}
+	case *array.Float64Builder:
+		switch v := value.(type) {
+		case int:
+			b.Append(float64(v))
+		case int32:
+			b.Append(float64(v))
+		case int64:
+			b.Append(float64(v))
+		case float32:
+			b.Append(float64(v))
+		case float64:
+			b.Append(v)
+		default:
+			return changedTypeError(field, value)
+		}
+	case *array.StringBuilder:
+		if s, ok := value.(string); ok {
+			b.Append(s)
+		} else {
+			b.Append(fmt.Sprint(value))
+		}
+	}
+	return nil
+}
+
+// changedTypeError reports a metadata value that doesn't fit the column inferred by the first scan,
+// when the document was updated in between
+func changedTypeError(field arrow.Field, value any) error {
+	return fmt.Errorf("metadata %q changed to %T while writing, its column is %s", field.Name, value, field.Type)
+}
+
+// ReadParquet upserts the documents of a parquet file written by WriteParquet into c, returning
+// the number of documents read
+func ReadParquet(ctx context.Context, r File, c chroma.Collection, opts Options) (int, error) {
+	pf, err := file.NewParquetReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer pf.Close()
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: int64(opts.batchSize())},
+		memory.DefaultAllocator)
+	if err != nil {
+		return 0, err
+	}
+	rr, err := fr.GetRecordReader(ctx, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer rr.Release()
+	read := 0
+	for rr.Next() {
+		n, err := upsert(ctx, rr.Record(), c, opts)
+		read += n
+		if err != nil {
+			return read, err
+		}
+	}
+	if err := rr.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return read, err
+	}
+	return read, nil
+}
+
+// ReadArrow upserts the documents of an Arrow IPC file written by WriteArrow into c, returning the
+// number of documents read
+func ReadArrow(ctx context.Context, r File, c chroma.Collection, opts Options) (int, error) {
+	fr, err := ipc.NewFileReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer fr.Close()
+	read := 0
+	for i := 0; i < fr.NumRecords(); i++ {
+		rec, err := fr.Record(i)
+		if err != nil {
+			return read, err
+		}
+		n, err := upsert(ctx, rec, c, opts)
+		read += n
+		if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+// upsert writes the documents of rec to c in batches
+func upsert(ctx context.Context, rec arrow.Record, c chroma.Collection, opts Options) (int, error) {
+	docs, err := documents(rec)
+	if err != nil {
+		return 0, err
+	}
+	written := 0
+	for _, batch := range chroma.SliceBatch(docs, opts.batchSize()) {
+		if len(batch) == 0 {
+			continue
+		}
+		if err := c.UpsertContext(ctx, batch, opts.Embedder); err != nil {
+			return written, err
+		}
+		written += len(batch)
+	}
+	return written, nil
+}
+
+// documents converts the rows of rec to documents
+func documents(rec arrow.Record) ([]chroma.Document, error) {
+	docs := make([]chroma.Document, rec.NumRows())
+	for col, field := range rec.Schema().Fields() {
+		column := rec.Column(col)
+		for row := range docs {
+			if column.IsNull(row) {
+				continue
+			}
+			switch field.Name {
+			case IDColumn:
+				id, err := stringValue(column, row)
+				if err != nil {
+					return nil, err
+				}
+				docs[row].ID = id
+			case ContentColumn:
+				content, err := stringValue(column, row)
+				if err != nil {
+					return nil, err
+				}
+				docs[row].Content = content
+			case EmbeddingColumn:
+				list, ok := column.(array.ListLike)
+				if !ok {
+					return nil, fmt.Errorf("column %s is a %s, expected a list of floats", field.Name, field.Type)
+				}
+				values, ok := list.ListValues().(*array.Float32)
+				if !ok {
+					return nil, fmt.Errorf("column %s is a %s, expected a list of float32", field.Name, field.Type)
+				}
+				start, end := list.ValueOffsets(row)
+				docs[row].Embeddings = append([]float32(nil), values.Float32Values()[start:end]...)
+			default:
+				value, err := metadataValue(column, row)
+				if err != nil {
+					return nil, fmt.Errorf("column %s: %w", field.Name, err)
+				}
+				if docs[row].Metadata == nil {
+					docs[row].Metadata = map[string]any{}
+				}
+				docs[row].Metadata[field.Name] = value
+			}
+		}
+	}
+	for row, doc := range docs {
+		if doc.ID == "" {
+			return nil, fmt.Errorf("row %d has no id", row)
+		}
+	}
+	return docs, nil
+}
+
+func stringValue(column arrow.Array, row int) (string, error) {
+	switch c := column.(type) {
+	case *array.String:
+		return c.Value(row), nil
+	case *array.LargeString:
+		return c.Value(row), nil
+	default:
+		return "", fmt.Errorf("column of type %s, expected a string", column.DataType())
+	}
+}
+
+func metadataValue(column arrow.Array, row int) (any, error) {
+	switch c := column.(type) {
+	case *array.Boolean:
+		return c.Value(row), nil
+	case *array.Int32:
+		return int64(c.Value(row)), nil
+	case *array.Int64:
+		return c.Value(row), nil
+	case *array.Float32:
+		return float64(c.Value(row)), nil
+	case *array.Float64:
+		return c.Value(row), nil
+	case *array.String, *array.LargeString:
+		return stringValue(column, row)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", column.DataType())
+	}
+}
@@ -0,0 +1,13 @@
+package chromaarrow_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestChromaarrow(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Chromaarrow Suite")
+}
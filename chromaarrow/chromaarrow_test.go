@@ -0,0 +1,202 @@
+package chromaarrow_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/parquet/file"
+	"github.com/apache/arrow/go/v15/parquet/pqarrow"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/chromaarrow"
+)
+
+type storedDoc struct {
+	ID        string
+	Document  string
+	Metadata  map[string]any
+	Embedding []float32
+}
+
+// documentStore serves the get and upsert endpoints of collections named after their ids
+type documentStore struct {
+	mu       sync.Mutex
+	docs     map[string][]storedDoc
+	afterGet func() // called with the lock held after serving a get
+}
+
+func (s *documentStore) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/api/v1/collections/"), "/")
+	if len(parts) == 1 {
+		json.NewEncoder(rw).Encode(map[string]any{
+			"id": parts[0], "name": parts[0], "metadata": map[string]any{"hnsw:space": "cosine"},
+		})
+		return
+	}
+	body := struct {
+		IDs        []string         `json:"ids"`
+		Embeddings [][]float32      `json:"embeddings"`
+		Metadatas  []map[string]any `json:"metadatas"`
+		Documents  []string         `json:"documents"`
+		Limit      int              `json:"limit"`
+		Offset     int              `json:"offset"`
+		Include    []string         `json:"include"`
+	}{}
+	Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+	switch parts[1] {
+	case "upsert":
+		for i, id := range body.IDs {
+			s.docs[parts[0]] = append(s.docs[parts[0]], storedDoc{
+				ID: id, Document: body.Documents[i], Metadata: body.Metadatas[i], Embedding: body.Embeddings[i],
+			})
+		}
+		rw.Write([]byte("true"))
+	case "get":
+		docs := s.docs[parts[0]]
+		docs = docs[min(body.Offset, len(docs)):]
+		docs = docs[:min(body.Limit, len(docs))]
+		out := map[string]any{}
+		var ids, documents []string
+		var metadatas []map[string]any
+		var embeddings [][]float32
+		for _, d := range docs {
+			ids = append(ids, d.ID)
+			documents = append(documents, d.Document)
+			metadatas = append(metadatas, d.Metadata)
+			embeddings = append(embeddings, d.Embedding)
+		}
+		out["ids"] = ids
+		for _, include := range body.Include {
+			out[include] = map[string]any{"documents": documents, "metadatas": metadatas, "embeddings": embeddings}[include]
+		}
+		json.NewEncoder(rw).Encode(out)
+		if s.afterGet != nil {
+			s.afterGet()
+		}
+	}
+}
+
+var _ = Describe("Parquet and Arrow files", func() {
+	var store *documentStore
+	var server *httptest.Server
+	var source, target chroma.Collection
+
+	BeforeEach(func() {
+		store = &documentStore{docs: map[string][]storedDoc{"source": {
+			{ID: "1", Document: "a", Metadata: map[string]any{"lang": "en", "rank": 1, "score": 0.5, "mixed": 1},
+				Embedding: []float32{1, 2}},
+			{ID: "2", Document: "b", Metadata: map[string]any{"lang": "fr", "rank": 2, "score": 1, "mixed": "x"},
+				Embedding: []float32{3, 4}},
+			{ID: "3", Document: "c", Metadata: map[string]any{"draft": true}, Embedding: []float32{5, 6}},
+		}}}
+		server = httptest.NewServer(store)
+		client, err := chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		source, err = client.GetCollection("source")
+		Expect(err).ToNot(HaveOccurred())
+		target, err = client.GetCollection("target")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	expectCopied := func() {
+		Expect(store.docs["target"]).To(HaveLen(3))
+		Expect(store.docs["target"][0]).To(Equal(storedDoc{
+			ID: "1", Document: "a", Embedding: []float32{1, 2},
+			Metadata: map[string]any{"lang": "en", "rank": 1.0, "score": 0.5, "mixed": "1"},
+		}))
+		Expect(store.docs["target"][2]).To(Equal(storedDoc{
+			ID: "3", Document: "c", Embedding: []float32{5, 6}, Metadata: map[string]any{"draft": true},
+		}))
+	}
+
+	It("writes parquet files with typed columns and reads them back", func() {
+		buf := &bytes.Buffer{}
+		n, err := chromaarrow.WriteParquet(context.Background(), source, buf, chromaarrow.Options{BatchSize: 2})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(3))
+
+		pf, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+		Expect(err).ToNot(HaveOccurred())
+		fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+		Expect(err).ToNot(HaveOccurred())
+		schema, err := fr.Schema()
+		Expect(err).ToNot(HaveOccurred())
+		types := map[string]string{}
+		for _, field := range schema.Fields() {
+			types[field.Name] = field.Type.String()
+		}
+		Expect(types).To(Equal(map[string]string{
+			"id": "utf8", "content": "utf8", "embedding": "fixed_size_list<item: float32, nullable>[2]",
+			"draft": "bool", "lang": "utf8", "mixed": "utf8", "rank": "int64", "score": "float64",
+		}))
+		collection, ok := schema.Metadata().GetValue(chromaarrow.CollectionMetadataKey)
+		Expect(ok).To(BeTrue())
+		Expect(collection).To(ContainSubstring(`"name":"source"`))
+		Expect(collection).To(ContainSubstring(`"distanceFn":"cosine"`))
+
+		n, err = chromaarrow.ReadParquet(context.Background(), bytes.NewReader(buf.Bytes()), target, chromaarrow.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(3))
+		expectCopied()
+	})
+
+	It("writes arrow files and reads them back", func() {
+		buf := &bytes.Buffer{}
+		n, err := chromaarrow.WriteArrow(context.Background(), source, buf, chromaarrow.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(3))
+
+		fr, err := ipc.NewFileReader(bytes.NewReader(buf.Bytes()))
+		Expect(err).ToNot(HaveOccurred())
+		field, ok := fr.Schema().FieldsByName("embedding")
+		Expect(ok).To(BeTrue())
+		Expect(field[0].Type).To(Equal(arrow.FixedSizeListOf(2, arrow.PrimitiveTypes.Float32)))
+
+		n, err = chromaarrow.ReadArrow(context.Background(), bytes.NewReader(buf.Bytes()), target, chromaarrow.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(3))
+		expectCopied()
+	})
+
+	It("fails when metadata changes type between the scans", func() {
+		store.afterGet = func() {
+			store.docs["source"][2].Metadata["draft"] = "yes"
+		}
+		_, err := chromaarrow.WriteArrow(context.Background(), source, &bytes.Buffer{}, chromaarrow.Options{})
+		Expect(err).To(MatchError(ContainSubstring(`metadata "draft" changed to string`)))
+	})
+
+	It("fails when metadata is added between the scans", func() {
+		store.afterGet = func() {
+			store.docs["source"][2].Metadata["reviewed"] = true
+		}
+		_, err := chromaarrow.WriteArrow(context.Background(), source, &bytes.Buffer{}, chromaarrow.Options{})
+		Expect(err).To(MatchError(ContainSubstring(`metadata "reviewed" was added while writing`)))
+	})
+
+	It("writes empty collections", func() {
+		store.docs["source"] = nil
+		buf := &bytes.Buffer{}
+		n, err := chromaarrow.WriteArrow(context.Background(), source, buf, chromaarrow.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(0))
+		n, err = chromaarrow.ReadArrow(context.Background(), bytes.NewReader(buf.Bytes()), target, chromaarrow.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(0))
+	})
+})
@@ -0,0 +1,230 @@
+// Package chromametrics collects chroma client and embedding metrics and serves them in the
+// Prometheus text exposition format, without depending on the Prometheus client library
+package chromametrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+// DefaultBuckets are the latency histogram buckets in seconds, the same as Prometheus' defaults
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector implements chroma.Instrumentation and embeddings.UsageTracker and exposes what it
+// collects as Prometheus metrics. Wire it with chroma.WithInstrumentation and the embedders'
+// usage tracker options, and serve it on a metrics endpoint
+type Collector struct {
+	namespace string
+	buckets   []float64
+
+	mu             sync.Mutex
+	requests       map[string]float64 // by operation
+	errors         map[[2]string]float64
+	durations      map[string]*histogram
+	documents      map[string]float64
+	tokens         map[string]float64 // by model
+	embedRequests  map[string]float64
+	embedDurations map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Option configures a Collector
+type Option func(*Collector)
+
+// WithNamespace sets the prefix of all metric names. Defaults to "chroma"
+func WithNamespace(namespace string) Option {
+	return func(c *Collector) {
+		c.namespace = namespace
+	}
+}
+
+// WithBuckets sets the upper bounds, in seconds, of the latency histogram buckets
+func WithBuckets(buckets ...float64) Option {
+	return func(c *Collector) {
+		c.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(c.buckets)
+	}
+}
+
+// NewCollector returns an empty Collector
+func NewCollector(opts ...Option) *Collector {
+	c := &Collector{
+		namespace:      "chroma",
+		buckets:        DefaultBuckets,
+		requests:       map[string]float64{},
+		errors:         map[[2]string]float64{},
+		durations:      map[string]*histogram{},
+		documents:      map[string]float64{},
+		tokens:         map[string]float64{},
+		embedRequests:  map[string]float64{},
+		embedDurations: map[string]*histogram{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+var _ chroma.Instrumentation = (*Collector)(nil)
+var _ embeddings.UsageTracker = (*Collector)(nil)
+
+func (c *Collector) RequestStart(ctx context.Context, _ chroma.RequestInfo) context.Context {
+	return ctx
+}
+
+func (c *Collector) RequestEnd(_ context.Context, info chroma.RequestInfo, result chroma.RequestResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	op := string(info.Operation)
+	c.requests[op]++
+	if result.Err != nil {
+		c.errors[[2]string{op, ErrorType(result.Err)}]++
+	}
+	c.observe(c.durations, op, result.Duration.Seconds())
+	if info.Documents > 0 && result.Err == nil {
+		c.documents[op] += float64(info.Documents)
+	}
+}
+
+func (c *Collector) TrackUsage(_ context.Context, usage embeddings.Usage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[usage.Model] += float64(usage.Tokens)
+	c.embedRequests[usage.Model] += float64(usage.Requests)
+	c.observe(c.embedDurations, usage.Model, usage.Latency.Seconds())
+}
+
+func (c *Collector) observe(histograms map[string]*histogram, key string, value float64) {
+	h, ok := histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.buckets))}
+		histograms[key] = h
+	}
+	h.count++
+	h.sum += value
+	for i, upper := range c.buckets {
+		if value <= upper {
+			h.counts[i]++
+			break
+		}
+	}
+}
+
+// ErrorType classifies request errors for the errors metric: "timeout", "canceled",
+// "client_error" and "server_error" for error status codes, or "transport"
+func ErrorType(err error) string {
+	var statusErr *chroma.StatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= http.StatusInternalServerError:
+		return "server_error"
+	case errors.As(err, &statusErr):
+		return "client_error"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "transport"
+	}
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (c *Collector) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(rw)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := &strings.Builder{}
+	c.writeCounter(b, "client_requests_total", "Requests sent to the chroma server.", "operation", c.requests)
+	c.writeHeader(b, "client_errors_total", "Failed requests to the chroma server by error type.", "counter")
+	for _, key := range sortedKeys(c.errors) {
+		fmt.Fprintf(b, "%s{operation=%s,type=%s} %s\n", c.name("client_errors_total"),
+			quote(key[0]), quote(key[1]), formatFloat(c.errors[key]))
+	}
+	c.writeHistogram(b, "client_request_duration_seconds", "Latency of requests to the chroma server.",
+		"operation", c.durations)
+	c.writeCounter(b, "client_documents_written_total", "Documents written to the chroma server.",
+		"operation", c.documents)
+	c.writeCounter(b, "embedding_tokens_total", "Tokens billed by embedding providers.", "model", c.tokens)
+	c.writeCounter(b, "embedding_requests_total", "Requests sent to embedding providers.", "model", c.embedRequests)
+	c.writeHistogram(b, "embedding_request_duration_seconds", "Latency of requests to embedding providers.",
+		"model", c.embedDurations)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (c *Collector) name(metric string) string {
+	if c.namespace == "" {
+		return metric
+	}
+	return c.namespace + "_" + metric
+}
+
+func (c *Collector) writeHeader(b *strings.Builder, metric, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", c.name(metric), help, c.name(metric), kind)
+}
+
+func (c *Collector) writeCounter(b *strings.Builder, metric, help, label string, values map[string]float64) {
+	c.writeHeader(b, metric, help, "counter")
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(b, "%s{%s=%s} %s\n", c.name(metric), label, quote(key), formatFloat(values[key]))
+	}
+}
+
+func (c *Collector) writeHistogram(b *strings.Builder, metric, help, label string, values map[string]*histogram) {
+	c.writeHeader(b, metric, help, "histogram")
+	name := c.name(metric)
+	for _, key := range sortedKeys(values) {
+		h := values[key]
+		var cumulative uint64
+		for i, upper := range c.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "%s_bucket{%s=%s,le=%s} %d\n", name, label, quote(key), quote(formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket{%s=%s,le=\"+Inf\"} %d\n", name, label, quote(key), h.count)
+		fmt.Fprintf(b, "%s_sum{%s=%s} %s\n", name, label, quote(key), formatFloat(h.sum))
+		fmt.Fprintf(b, "%s_count{%s=%s} %d\n", name, label, quote(key), h.count)
+	}
+}
+
+// quote quotes a label value, escaping backslashes, double quotes and line feeds
+func quote(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return `"` + value + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[K string | [2]string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	return keys
+}
@@ -0,0 +1,13 @@
+package chromametrics_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestChromametrics(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Chromametrics Suite")
+}
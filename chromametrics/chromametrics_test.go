@@ -0,0 +1,122 @@
+package chromametrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/chromametrics"
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+type testEmbedder struct{}
+
+func (testEmbedder) EmbedDocuments(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i := range texts {
+		vectors[i] = []float32{1, 2}
+	}
+	return vectors, nil
+}
+
+func (testEmbedder) EmbedQuery(_ context.Context, _ string) ([]float32, error) {
+	return []float32{1, 2}, nil
+}
+
+func scrape(collector *chromametrics.Collector) string {
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	Expect(resp.Header.Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+	body, err := io.ReadAll(resp.Body)
+	Expect(err).ToNot(HaveOccurred())
+	return string(body)
+}
+
+var _ = Describe("Prometheus metrics", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/api/v1/collections/c1/add":
+				rw.Write([]byte("true"))
+			case "/api/v1/collections/c1/count":
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte(`{"error": "boom"}`))
+			default:
+				rw.Write([]byte(`{"id": "c1", "name": "measured", "metadata": {"hnsw:space": "l2"}}`))
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("collects client requests, errors, latencies and documents written", func() {
+		collector := chromametrics.NewCollector(chromametrics.WithBuckets(0.5, 60))
+		client, err := chroma.NewClient(server.URL, chroma.WithInstrumentation(collector))
+		Expect(err).ToNot(HaveOccurred())
+		collection, err := client.GetCollection("measured")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Add([]chroma.Document{{ID: "1", Content: "a"}, {ID: "2", Content: "b"}},
+			testEmbedder{})).To(Succeed())
+		_, err = collection.Count()
+		Expect(err).To(HaveOccurred())
+
+		metrics := scrape(collector)
+		Expect(metrics).To(ContainSubstring("# TYPE chroma_client_requests_total counter\n"))
+		Expect(metrics).To(ContainSubstring(`chroma_client_requests_total{operation="add"} 1` + "\n"))
+		Expect(metrics).To(ContainSubstring(`chroma_client_requests_total{operation="count"} 1` + "\n"))
+		Expect(metrics).To(ContainSubstring(`chroma_client_requests_total{operation="getCollection"} 1` + "\n"))
+		Expect(metrics).To(ContainSubstring(
+			`chroma_client_errors_total{operation="count",type="server_error"} 1` + "\n"))
+		Expect(metrics).To(ContainSubstring("# TYPE chroma_client_request_duration_seconds histogram\n"))
+		Expect(metrics).To(ContainSubstring(
+			`chroma_client_request_duration_seconds_bucket{operation="add",le="60"} 1` + "\n"))
+		Expect(metrics).To(ContainSubstring(
+			`chroma_client_request_duration_seconds_bucket{operation="add",le="+Inf"} 1` + "\n"))
+		Expect(metrics).To(ContainSubstring(`chroma_client_request_duration_seconds_count{operation="add"} 1` + "\n"))
+		Expect(metrics).To(ContainSubstring(`chroma_client_documents_written_total{operation="add"} 2` + "\n"))
+	})
+
+	It("collects embedding token usage", func() {
+		collector := chromametrics.NewCollector(chromametrics.WithNamespace("app"))
+		collector.TrackUsage(context.Background(), embeddings.Usage{
+			Model: "ada", Tokens: 10, Requests: 1, Latency: 30 * time.Millisecond})
+		collector.TrackUsage(context.Background(), embeddings.Usage{
+			Model: "ada", Tokens: 5, Requests: 1, Latency: 2 * time.Second})
+
+		metrics := scrape(collector)
+		Expect(metrics).To(ContainSubstring(`app_embedding_tokens_total{model="ada"} 15` + "\n"))
+		Expect(metrics).To(ContainSubstring(`app_embedding_requests_total{model="ada"} 2` + "\n"))
+		Expect(metrics).To(ContainSubstring(`app_embedding_request_duration_seconds_bucket{model="ada",le="0.05"} 1` + "\n"))
+		Expect(metrics).To(ContainSubstring(`app_embedding_request_duration_seconds_bucket{model="ada",le="2.5"} 2` + "\n"))
+		Expect(metrics).To(ContainSubstring(`app_embedding_request_duration_seconds_sum{model="ada"} 2.03` + "\n"))
+	})
+
+	It("escapes label values", func() {
+		collector := chromametrics.NewCollector()
+		collector.TrackUsage(context.Background(), embeddings.Usage{Model: "a\"b\\c\nd", Tokens: 1})
+		Expect(scrape(collector)).To(ContainSubstring(`chroma_embedding_tokens_total{model="a\"b\\c\nd"} 1`))
+	})
+
+	It("classifies errors", func() {
+		Expect(chromametrics.ErrorType(&chroma.StatusError{StatusCode: 404})).To(Equal("client_error"))
+		Expect(chromametrics.ErrorType(&chroma.StatusError{StatusCode: 503})).To(Equal("server_error"))
+		Expect(chromametrics.ErrorType(context.DeadlineExceeded)).To(Equal("timeout"))
+		Expect(chromametrics.ErrorType(context.Canceled)).To(Equal("canceled"))
+		Expect(chromametrics.ErrorType(errors.New("connection refused"))).To(Equal("transport"))
+		Expect(strings.Count(scrape(chromametrics.NewCollector()), "# TYPE")).To(Equal(7))
+	})
+})
@@ -0,0 +1,172 @@
+// Package chromaotel reports chroma client requests and embedder calls as OpenTelemetry spans and metrics
+package chromaotel
+
+import (
+	"context"
+
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/embeddings"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/urjitbhatia/gochroma/chromaotel"
+
+// Instrumentation implements chroma.Instrumentation and embeddings.EmbedTracer
+type Instrumentation struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+
+	requestDuration metric.Float64Histogram
+	requests        metric.Int64Counter
+	documents       metric.Int64Counter
+	embedDuration   metric.Float64Histogram
+	embedTexts      metric.Int64Counter
+}
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// Option configures an Instrumentation
+type Option func(*config)
+
+// WithTracerProvider sets the tracer provider. Defaults to the global one
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider. Defaults to the global one
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagator sets the propagator injecting trace context into requests to the chroma server.
+// Defaults to the global one
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// New returns an Instrumentation, to be given to chroma.WithInstrumentation and embeddings.Trace
+func New(opts ...Option) (*Instrumentation, error) {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	meter := c.meterProvider.Meter(instrumentationName)
+	i := &Instrumentation{
+		tracer:     c.tracerProvider.Tracer(instrumentationName),
+		propagator: c.propagator,
+	}
+	var err error
+	if i.requestDuration, err = meter.Float64Histogram("chroma.client.request.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of requests to the chroma server")); err != nil {
+		return nil, err
+	}
+	if i.requests, err = meter.Int64Counter("chroma.client.requests",
+		metric.WithDescription("Requests sent to the chroma server")); err != nil {
+		return nil, err
+	}
+	if i.documents, err = meter.Int64Counter("chroma.client.documents",
+		metric.WithDescription("Documents sent to the chroma server")); err != nil {
+		return nil, err
+	}
+	if i.embedDuration, err = meter.Float64Histogram("chroma.embedder.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of embedder calls")); err != nil {
+		return nil, err
+	}
+	if i.embedTexts, err = meter.Int64Counter("chroma.embedder.texts",
+		metric.WithDescription("Texts embedded")); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+var _ chroma.Instrumentation = (*Instrumentation)(nil)
+var _ embeddings.EmbedTracer = (*Instrumentation)(nil)
+
+func (i *Instrumentation) RequestStart(ctx context.Context, info chroma.RequestInfo) context.Context {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "chromadb"),
+		attribute.String("db.operation", string(info.Operation)),
+		attribute.String("http.request.method", info.Method),
+		attribute.String("url.path", info.Path),
+	}
+	if info.CollectionID != "" {
+		attrs = append(attrs, attribute.String("db.chroma.collection_id", info.CollectionID))
+	}
+	if info.Documents > 0 {
+		attrs = append(attrs, attribute.Int("db.chroma.documents", info.Documents))
+	}
+	ctx, _ = i.tracer.Start(ctx, "chroma."+string(info.Operation),
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	if info.Header != nil {
+		i.propagator.Inject(ctx, propagation.HeaderCarrier(info.Header))
+	}
+	return ctx
+}
+
+func (i *Instrumentation) RequestEnd(ctx context.Context, info chroma.RequestInfo, result chroma.RequestResult) {
+	span := trace.SpanFromContext(ctx)
+	if result.StatusCode != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", result.StatusCode))
+	}
+	if result.Err != nil {
+		span.RecordError(result.Err)
+		span.SetStatus(codes.Error, result.Err.Error())
+	}
+	span.End()
+
+	attrs := metric.WithAttributes(
+		attribute.String("db.operation", string(info.Operation)),
+		attribute.Bool("error", result.Err != nil),
+	)
+	i.requestDuration.Record(ctx, result.Duration.Seconds(), attrs)
+	i.requests.Add(ctx, 1, attrs)
+	if info.Documents > 0 {
+		i.documents.Add(ctx, int64(info.Documents), metric.WithAttributes(
+			attribute.String("db.operation", string(info.Operation))))
+	}
+}
+
+func (i *Instrumentation) EmbedStart(ctx context.Context, call embeddings.EmbedCall) context.Context {
+	ctx, _ = i.tracer.Start(ctx, "embedder."+call.Operation, trace.WithAttributes(
+		attribute.String("embedder.model", call.Model),
+		attribute.Int("embedder.texts", call.Texts),
+	))
+	return ctx
+}
+
+func (i *Instrumentation) EmbedEnd(ctx context.Context, call embeddings.EmbedCall) {
+	span := trace.SpanFromContext(ctx)
+	if call.Err != nil {
+		span.RecordError(call.Err)
+		span.SetStatus(codes.Error, call.Err.Error())
+	}
+	span.End()
+
+	attrs := metric.WithAttributes(
+		attribute.String("embedder.operation", call.Operation),
+		attribute.String("embedder.model", call.Model),
+		attribute.Bool("error", call.Err != nil),
+	)
+	i.embedDuration.Record(ctx, call.Duration.Seconds(), attrs)
+	i.embedTexts.Add(ctx, int64(call.Texts), attrs)
+}
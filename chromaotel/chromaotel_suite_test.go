@@ -0,0 +1,13 @@
+package chromaotel_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestChromaotel(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Chromaotel Suite")
+}
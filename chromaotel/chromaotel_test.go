@@ -0,0 +1,129 @@
+package chromaotel_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/chromaotel"
+	"github.com/urjitbhatia/gochroma/embeddings"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ = Describe("OpenTelemetry instrumentation", func() {
+	var spans *tracetest.SpanRecorder
+	var reader *sdkmetric.ManualReader
+	var tp *sdktrace.TracerProvider
+	var inst *chromaotel.Instrumentation
+	var server *httptest.Server
+	var traceparents []string
+
+	BeforeEach(func() {
+		traceparents = nil
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			traceparents = append(traceparents, req.Header.Get("traceparent"))
+			switch req.URL.Path {
+			case "/api/v1/collections/c1/count":
+				rw.Write([]byte("2"))
+			case "/api/v1/collections/c1/add":
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte(`{"error": "boom"}`))
+			default:
+				rw.Write([]byte(`{"id": "c1", "name": "traced", "metadata": {"hnsw:space": "l2"}}`))
+			}
+		}))
+
+		spans = tracetest.NewSpanRecorder()
+		tp = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+		reader = sdkmetric.NewManualReader()
+		var err error
+		inst, err = chromaotel.New(
+			chromaotel.WithTracerProvider(tp),
+			chromaotel.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+			chromaotel.WithPropagator(propagation.TraceContext{}))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("records spans and metrics for client requests", func() {
+		client, err := chroma.NewClient(server.URL, chroma.WithInstrumentation(inst))
+		Expect(err).ToNot(HaveOccurred())
+
+		ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+		collection, err := client.GetCollection("traced")
+		Expect(err).ToNot(HaveOccurred())
+		count, err := collection.CountContext(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(count).To(Equal(2))
+		err = collection.AddContext(ctx, []chroma.Document{{ID: "1", Embeddings: []float32{1}}}, nil)
+		Expect(err).To(HaveOccurred())
+		parent.End()
+
+		ended := spans.Ended()
+		Expect(ended).To(HaveLen(4))
+		Expect(ended[0].Name()).To(Equal("chroma.getCollection"))
+		Expect(ended[0].Parent().IsValid()).To(BeFalse())
+
+		countSpan := ended[1]
+		Expect(countSpan.Name()).To(Equal("chroma.count"))
+		Expect(countSpan.Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+		Expect(countSpan.Attributes()).To(ContainElements(
+			attribute.String("db.operation", "count"),
+			attribute.String("db.chroma.collection_id", "c1"),
+			attribute.Int("http.response.status_code", 200)))
+
+		addSpan := ended[2]
+		Expect(addSpan.Name()).To(Equal("chroma.add"))
+		Expect(addSpan.Status().Code).To(Equal(codes.Error))
+		Expect(addSpan.Attributes()).To(ContainElement(attribute.Int("db.chroma.documents", 1)))
+
+		// trace context is propagated to the server
+		Expect(traceparents[1]).To(ContainSubstring(countSpan.SpanContext().SpanID().String()))
+
+		rm := metricdata.ResourceMetrics{}
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+		names := map[string]bool{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				names[m.Name] = true
+				if m.Name == "chroma.client.requests" {
+					var total int64
+					for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+						total += dp.Value
+					}
+					Expect(total).To(Equal(int64(3)))
+				}
+			}
+		}
+		Expect(names).To(HaveKey("chroma.client.request.duration"))
+		Expect(names).To(HaveKey("chroma.client.documents"))
+	})
+
+	It("traces embedder calls", func() {
+		e := embeddings.Trace(inst)(embeddings.NewHashEmbedder(4))
+		_, err := e.EmbedDocuments(context.Background(), []string{"a", "b"})
+		Expect(err).ToNot(HaveOccurred())
+
+		ended := spans.Ended()
+		Expect(ended).To(HaveLen(1))
+		Expect(ended[0].Name()).To(Equal("embedder.EmbedDocuments"))
+		Expect(ended[0].Attributes()).To(ContainElement(attribute.Int("embedder.texts", 2)))
+
+		rm := metricdata.ResourceMetrics{}
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+		Expect(rm.ScopeMetrics).To(HaveLen(1))
+		Expect(rm.ScopeMetrics[0].Metrics).To(HaveLen(2))
+	})
+})
@@ -2,17 +2,95 @@ package chroma
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/urjitbhatia/gochroma/embeddings"
+	"github.com/urjitbhatia/gochroma/internal/logging"
+	"github.com/urjitbhatia/gochroma/internal/ratelimit"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Client struct {
-	url        string
-	httpClient http.Client
+	url             string
+	httpClient      http.Client
+	defaultEmbedder embeddings.Embedder
+	logger          *slog.Logger
+	instrumentation []Instrumentation
+	limiters        map[OperationClass]ratelimit.Limiter
+	breaker         *CircuitBreaker
+
+	aliases    bool
+	registryMu sync.Mutex
+	registry   *Collection // alias registry, once fetched
+
+	endpointURLs    []string
+	endpoints       []*endpoint
+	endpointsMu     sync.Mutex
+	policy          SelectionPolicy
+	next            int // round robin position
+	ejectAfter      int
+	ejectCooldown   time.Duration
+	writeFailover   bool
+	collectionNames map[string]string // collection ids to names, with multiple endpoints
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithLogger logs every request to the server with handler: failed requests at error level,
+// error responses at warn level and everything else at debug level. Nothing is logged by default
+func WithLogger(handler slog.Handler) ClientOption {
+	return func(c *Client) {
+		c.logger = slog.New(handler)
+	}
+}
+
+// WithInstrumentation notifies inst around every request sent by the client and its collections.
+// It can be given multiple times, instrumentations are started in order and ended in reverse order
+func WithInstrumentation(inst Instrumentation) ClientOption {
+	return func(c *Client) {
+		c.instrumentation = append(c.instrumentation, inst)
+	}
+}
+
+// Limits caps the requests of an operation class. Zero values are unlimited
+type Limits struct {
+	RequestsPerSecond float64
+	Burst             int // requests allowed at once after idling, at least 1
+	MaxInFlight       int
+}
+
+// WithRateLimit makes requests of the given class wait until they fit within limits, or fail with
+// the context's error when it is done first. A request is in flight until its response is read
+func WithRateLimit(class OperationClass, limits Limits) ClientOption {
+	return func(c *Client) {
+		limiter := ratelimit.Limiter{}
+		if limits.RequestsPerSecond > 0 {
+			limiter.Bucket = ratelimit.NewBucket(limits.RequestsPerSecond, limits.Burst)
+		}
+		if limits.MaxInFlight > 0 {
+			limiter.Semaphore = ratelimit.NewSemaphore(limits.MaxInFlight)
+		}
+		if c.limiters == nil {
+			c.limiters = map[OperationClass]ratelimit.Limiter{}
+		}
+		c.limiters[class] = limiter
+	}
+}
+
+// WithDefaultEmbedder binds every collection returned by the client to embedder, unless the
+// collection records a different embedder in its metadata or is given one with WithEmbedder
+func WithDefaultEmbedder(embedder embeddings.Embedder) ClientOption {
+	return func(c *Client) {
+		c.defaultEmbedder = embedder
+	}
 }
 
 type Server interface {
@@ -24,20 +102,26 @@ type Chroma interface {
 	Reset() (bool, error)
 	GetVersion() (string, error)
 	ListCollections() ([]Collection, error)
-	GetOrCreateCollection(name string, distanceFn string, metadata map[string]any) (Collection, error)
-	CreateCollection(name string, distanceFn string, metadata map[string]any) (Collection, error)
+	GetOrCreateCollection(name string, distanceFn string, metadata map[string]any, opts ...CollectionOption) (Collection, error)
+	CreateCollection(name string, distanceFn string, metadata map[string]any, opts ...CollectionOption) (Collection, error)
 	DeleteCollection(name string) error
-	GetCollection(name string) (Collection, error)
+	GetCollection(name string, opts ...CollectionOption) (Collection, error)
 }
 
-func NewClient(serverURL string) (Chroma, error) {
+func NewClient(serverURL string, opts ...ClientOption) (Chroma, error) {
 	u, err := url.Parse(serverURL)
 	if err != nil {
 		return nil, err
 	}
-	u = u.JoinPath("api/v1")
-	c := &Client{url: u.String(), httpClient: http.Client{}}
-	return c, err
+	c := &Client{httpClient: http.Client{}, logger: logging.Discard,
+		ejectAfter: 1, ejectCooldown: 30 * time.Second, collectionNames: map[string]string{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.setEndpoints(u); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func (c *Client) BaseUrl() string {
@@ -45,10 +129,16 @@ func (c *Client) BaseUrl() string {
 }
 
 func (c *Client) Heartbeat() (int, error) {
-	resp, err := c.httpClient.Get(c.url + "/heartbeat")
+	return c.heartbeatContext(context.Background())
+}
+
+// heartbeatContext is Heartbeat with a context used for the request
+func (c *Client) heartbeatContext(ctx context.Context) (int, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/heartbeat", nil, RequestInfo{Operation: OpHeartbeat})
 	if err != nil {
 		return -1, err
 	}
+	defer resp.Body.Close()
 	value := -1
 	if resp.StatusCode != http.StatusOK {
 		return value,
@@ -61,7 +151,16 @@ func (c *Client) Heartbeat() (int, error) {
 }
 
 func (c *Client) Reset() (bool, error) {
-	resp, err := c.httpClient.Post(c.url+"/reset", "", nil)
+	// the alias registry is deleted along with every other collection
+	c.registryMu.Lock()
+	c.registry = nil
+	c.registryMu.Unlock()
+	c.forgetCollections("")
+	resp, err := c.send(context.Background(), http.MethodPost, "/reset", nil, RequestInfo{Operation: OpReset})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
 	// Chroma returns just the string "true/false" if reset is enabled otherwise a json object with
 	// an error string :facepalm:
 
@@ -90,10 +189,16 @@ func (c *Client) Reset() (bool, error) {
 }
 
 func (c *Client) GetVersion() (string, error) {
-	resp, err := c.httpClient.Get(c.url + "/version")
+	return c.versionContext(context.Background())
+}
+
+// versionContext is GetVersion with a context used for the request
+func (c *Client) versionContext(ctx context.Context) (string, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/version", nil, RequestInfo{Operation: OpVersion})
 	if err != nil {
 		return "", err
 	}
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	// server response string is wrapped in double quotes, remove that
 	body = bytes.ReplaceAll(body, []byte("\""), []byte(""))
@@ -101,31 +206,39 @@ func (c *Client) GetVersion() (string, error) {
 }
 
 func (c *Client) ListCollections() ([]Collection, error) {
-	resp, err := c.httpClient.Get(c.url + "/collections")
+	resp, err := c.send(context.Background(), http.MethodGet, "/collections", nil, RequestInfo{Operation: OpListCollections})
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	var collections []Collection
 	err = json.NewDecoder(resp.Body).Decode(&collections)
 	if err != nil {
 		return nil, err
 	}
-	for _, collection := range collections {
-		collection.server = c
+	for i := range collections {
+		c.prepareCollection(&collections[i], nil)
 	}
 	return collections, err
 }
 
-func (c *Client) GetOrCreateCollection(name string, distanceFn string, metadata map[string]any) (Collection, error) {
-	return c.createCollection(name, distanceFn, metadata, true)
+// GetOrCreateCollection returns the collection called name, creating it when it doesn't exist. On
+// clients created WithAliases it returns the collection aliased by name instead, if any
+func (c *Client) GetOrCreateCollection(name string, distanceFn string, metadata map[string]any, opts ...CollectionOption) (Collection, error) {
+	name, err := c.alias(name)
+	if err != nil {
+		return Collection{}, err
+	}
+	return c.createCollection(name, distanceFn, metadata, true, opts)
 }
 
-func (c *Client) CreateCollection(name string, distanceFn string, metadata map[string]any) (Collection, error) {
-	return c.createCollection(name, distanceFn, metadata, false)
+func (c *Client) CreateCollection(name string, distanceFn string, metadata map[string]any, opts ...CollectionOption) (Collection, error) {
+	return c.createCollection(name, distanceFn, metadata, false, opts)
 }
 
-func (c *Client) createCollection(name string, distanceFn string, metadata map[string]any, getOrCreate bool) (Collection, error) {
+func (c *Client) createCollection(name string, distanceFn string, metadata map[string]any, getOrCreate bool,
+	opts []CollectionOption) (Collection, error) {
 	if metadata == nil {
 		metadata = map[string]any{}
 	}
@@ -134,6 +247,21 @@ func (c *Client) createCollection(name string, distanceFn string, metadata map[s
 	} else {
 		metadata["hnsw:space"] = strings.ToLower(distanceFn)
 	}
+	options := applyCollectionOptions(opts)
+	if getOrCreate && options.embedderName != "" && options.embedderDimension == 0 {
+		// an existing collection records the dimension, sparing a paid probe call for a request
+		if existing, err := c.getCollection(name, nil); err == nil {
+			info, _ := existing.EmbedderInfo()
+			options.embedderDimension = info.Dimension
+		}
+	}
+	embedder, embedderMetadata, err := options.embedderMetadata()
+	if err != nil {
+		return Collection{}, err
+	}
+	for k, v := range embedderMetadata {
+		metadata[k] = v
+	}
 	data := map[string]any{
 		"name": name, "metadata": metadata, "get_or_create": getOrCreate,
 	}
@@ -144,10 +272,12 @@ func (c *Client) createCollection(name string, distanceFn string, metadata map[s
 		return collection, err
 	}
 
-	resp, err := c.httpClient.Post(c.url+"/collections", "application/json", bytes.NewReader(reqBody))
+	resp, err := c.send(context.Background(), http.MethodPost, "/collections", bytes.NewReader(reqBody),
+		RequestInfo{Operation: OpCreateCollection})
 	if err != nil {
 		return collection, err
 	}
+	defer resp.Body.Close()
 	bodyBuf, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return collection, err
@@ -165,18 +295,31 @@ func (c *Client) createCollection(name string, distanceFn string, metadata map[s
 	}
 	// not an error, convert to collection type
 	err = json.Unmarshal(bodyBuf, &collection)
+	if err != nil {
+		return collection, err
+	}
+	if embedder != nil {
+		// an existing collection returned by get or create may record another embedder
+		info, _ := collection.EmbedderInfo()
+		if info.Name != embedderMetadata[MetadataEmbedderName] || info.Model != embedderMetadata[MetadataEmbedderModel] {
+			return collection, fmt.Errorf("%w: collection %s records embedder %q model %q",
+				ErrEmbedderMismatch, name, info.Name, info.Model)
+		}
+	}
+	if options.embedder != nil {
+		embedder = options.embedder
+	}
+	err = c.prepareCollection(&collection, embedder)
 	return collection, err
 }
 
 func (c *Client) DeleteCollection(name string) error {
-	req, err := http.NewRequest(http.MethodDelete, c.url+"/collections/"+name, nil)
-	if err != nil {
-		return err
-	}
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.send(context.Background(), http.MethodDelete, "/collections/"+name, nil,
+		RequestInfo{Operation: OpDeleteCollection})
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	deleteResp := map[string]any{}
 	err = json.NewDecoder(resp.Body).Decode(&deleteResp)
@@ -186,21 +329,63 @@ func (c *Client) DeleteCollection(name string) error {
 	if errStr, ok := deleteResp["error"]; ok {
 		return fmt.Errorf("error deleting collection: %s", errStr)
 	}
+	c.forgetCollections(name)
 	return nil
 }
 
-func (c *Client) GetCollection(name string) (Collection, error) {
-	resp, err := c.httpClient.Get(c.url + "/collections/" + name)
+// GetCollection returns the collection called name, or the collection aliased by name on clients
+// created WithAliases
+func (c *Client) GetCollection(name string, opts ...CollectionOption) (Collection, error) {
+	name, err := c.alias(name)
 	if err != nil {
 		return Collection{}, err
 	}
+	return c.getCollection(name, opts)
+}
 
-	collection := Collection{server: c}
-	err = json.NewDecoder(resp.Body).Decode(&collection)
+// getCollection returns the collection called name, without resolving aliases
+func (c *Client) getCollection(name string, opts []CollectionOption) (Collection, error) {
+	resp, err := c.send(context.Background(), http.MethodGet, "/collections/"+name, nil,
+		RequestInfo{Operation: OpGetCollection})
 	if err != nil {
 		return Collection{}, err
 	}
+	defer resp.Body.Close()
 
-	collection.DistanceFn, _ = collection.Metadata["hnsw:space"].(string)
+	bodyBuf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Collection{}, err
+	}
+	respJSON := map[string]any{}
+	err = json.Unmarshal(bodyBuf, &respJSON)
+	if err != nil {
+		return Collection{}, err
+	}
+	if errStr, ok := respJSON["error"]; ok {
+		return Collection{}, fmt.Errorf("error getting collection: %s", errStr)
+	}
+
+	collection := Collection{}
+	err = json.Unmarshal(bodyBuf, &collection)
+	if err != nil {
+		return Collection{}, err
+	}
+	err = c.prepareCollection(&collection, applyCollectionOptions(opts).embedder)
+	if err != nil {
+		return Collection{}, err
+	}
 	return collection, nil
 }
+
+// prepareCollection sets up a collection decoded from a server response for use with this client,
+// binding it to embedder if given. It errors if embedder doesn't match the collection
+func (c *Client) prepareCollection(collection *Collection, embedder embeddings.Embedder) error {
+	collection.server = c
+	c.rememberCollection(*collection)
+	collection.DistanceFn, _ = collection.Metadata["hnsw:space"].(string)
+	collection.bindEmbedder(embedder, c.defaultEmbedder)
+	if embedder != nil {
+		return collection.checkModel(embedder)
+	}
+	return nil
+}
@@ -17,7 +17,9 @@ type Collection struct {
 	Metadata   map[string]any `json:"metadata"`
 	DistanceFn string         `json:"distanceFn"`
 
-	server Server
+	server        Server
+	embedder      embeddings.Embedder
+	expectedModel string
 }
 
 // CollectionWithSrv creates a collection with the given chroma server as the backend
@@ -74,7 +76,9 @@ func (c chromaCollectionObject) asDocuments() []Document {
 	docs := make([]Document, len(c.IDs))
 	for i := 0; i < len(c.IDs); i++ {
 		docs[i].ID = c.IDs[i]
-		docs[i].Content = c.Documents[i]
+		if c.Documents != nil {
+			docs[i].Content = c.Documents[i]
+		}
 		if c.Embeddings != nil {
 			docs[i].Embeddings = c.Embeddings[i]
 		}
@@ -85,7 +89,30 @@ func (c chromaCollectionObject) asDocuments() []Document {
 	return docs
 }
 
+// Add adds docs to the collection, generating embeddings for docs without them using embedder.
+// embedder may be nil for collections bound to an embedder
 func (c Collection) Add(docs []Document, embedder embeddings.Embedder) error {
+	return c.AddContext(context.Background(), docs, embedder)
+}
+
+// AddContext is Add with a context used for embedding and sending the documents
+func (c Collection) AddContext(ctx context.Context, docs []Document, embedder embeddings.Embedder) error {
+	return c.write(ctx, OpAdd, docs, embedder)
+}
+
+// Upsert adds docs to the collection, replacing the documents with the same ids. Embeddings are
+// generated like with Add
+func (c Collection) Upsert(docs []Document, embedder embeddings.Embedder) error {
+	return c.UpsertContext(context.Background(), docs, embedder)
+}
+
+// UpsertContext is Upsert with a context used for embedding and sending the documents
+func (c Collection) UpsertContext(ctx context.Context, docs []Document, embedder embeddings.Embedder) error {
+	return c.write(ctx, OpUpsert, docs, embedder)
+}
+
+// write sends docs to the collection's add or upsert endpoint
+func (c Collection) write(ctx context.Context, op Operation, docs []Document, embedder embeddings.Embedder) error {
 	addReq := chromaCollectionObject{
 		Embeddings: [][]float32{},
 		Metadatas:  []map[string]any{},
@@ -103,31 +130,45 @@ func (c Collection) Add(docs []Document, embedder embeddings.Embedder) error {
 		}
 	}
 
-	// get embeddings for docs that are missing embeddings using batch calls for efficiency
-	for _, batch := range SliceBatch(docsToFetchEmbeddings, 10) {
-		contents := make([]string, len(batch))
-		for i, doc := range batch {
-			contents[i] = doc.Content
-		}
-		addReq.Documents = append(addReq.Documents, contents...)
-
-		embedVectors, err := embedder.EmbedDocuments(context.Background(), contents)
+	if len(docsToFetchEmbeddings) > 0 {
+		var err error
+		embedder, err = c.resolveEmbedder(embedder)
 		if err != nil {
 			return err
 		}
-		addReq.Embeddings = append(addReq.Embeddings, embedVectors...)
 
-		for _, doc := range batch {
-			addReq.Metadatas = append(addReq.Metadatas, doc.Metadata)
-			addReq.IDs = append(addReq.IDs, doc.ID)
+		// get embeddings for docs that are missing embeddings using batch calls for efficiency
+		for _, batch := range SliceBatch(docsToFetchEmbeddings, 10) {
+			contents := make([]string, len(batch))
+			for i, doc := range batch {
+				contents[i] = doc.Content
+			}
+			addReq.Documents = append(addReq.Documents, contents...)
+
+			embedVectors, err := embedder.EmbedDocuments(ctx, contents)
+			if err != nil {
+				return err
+			}
+			addReq.Embeddings = append(addReq.Embeddings, embedVectors...)
+
+			for _, doc := range batch {
+				addReq.Metadatas = append(addReq.Metadatas, doc.Metadata)
+				addReq.IDs = append(addReq.IDs, doc.ID)
+			}
 		}
 	}
 
-	for i, doc := range docsWithEmbeddings {
-		addReq.Embeddings[i] = doc.Embeddings
-		addReq.Metadatas[i] = doc.Metadata
-		addReq.Documents[i] = doc.Content
-		addReq.IDs[i] = doc.ID
+	for _, doc := range docsWithEmbeddings {
+		addReq.Embeddings = append(addReq.Embeddings, doc.Embeddings)
+		addReq.Metadatas = append(addReq.Metadatas, doc.Metadata)
+		addReq.Documents = append(addReq.Documents, doc.Content)
+		addReq.IDs = append(addReq.IDs, doc.ID)
+	}
+
+	for _, vector := range addReq.Embeddings {
+		if err := c.checkDimension(vector); err != nil {
+			return err
+		}
 	}
 
 	body, err := json.Marshal(addReq)
@@ -135,10 +176,12 @@ func (c Collection) Add(docs []Document, embedder embeddings.Embedder) error {
 		return err
 	}
 
-	resp, err := http.DefaultClient.Post(
-		fmt.Sprintf("%s/collections/%s/add", c.server.BaseUrl(), c.ID),
-		"application/json",
-		bytes.NewBuffer(body))
+	verb := "adding"
+	if op == OpUpsert {
+		verb = "upserting"
+	}
+	resp, err := c.send(ctx, http.MethodPost, "/"+string(op), bytes.NewBuffer(body),
+		RequestInfo{Operation: op, Documents: len(addReq.IDs)})
 
 	if err != nil {
 		return err
@@ -147,32 +190,66 @@ func (c Collection) Add(docs []Document, embedder embeddings.Embedder) error {
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		bodyBuf, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("error adding documents: Unable to read response body: %w", err)
+			return fmt.Errorf("error %s documents: Unable to read response body: %w", verb, err)
 		}
-		return fmt.Errorf("error adding documents: %s", string(bodyBuf))
+		return fmt.Errorf("error %s documents: %s", verb, string(bodyBuf))
 	}
 	return nil
 }
 
-func (c Collection) Get(ids []string, where map[string]any, documents map[string]any) ([]Document, error) {
+// Delete deletes the documents with the given ids that match the where and where document filters.
+// All of them are optional, a call without any deletes every document of the collection
+func (c Collection) Delete(ids []string, where map[string]any, whereDocument map[string]any) error {
+	return c.DeleteContext(context.Background(), ids, where, whereDocument)
+}
+
+// DeleteContext is Delete with a context used for the request
+func (c Collection) DeleteContext(ctx context.Context, ids []string, where map[string]any, whereDocument map[string]any) error {
 	payload := map[string]any{
 		"ids":            ids,
 		"where":          where,
-		"where_document": documents,
+		"where_document": whereDocument,
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	req, err := http.NewRequest(
-		http.MethodPost,
-		c.server.BaseUrl()+"/collections/"+c.ID+"/get",
-		bytes.NewReader(body))
+	resp, err := c.send(ctx, http.MethodPost, "/delete", bytes.NewReader(body),
+		RequestInfo{Operation: OpDelete, Documents: len(ids)})
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBuf, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("error deleting documents: Unable to read response body: %w", err)
+		}
+		return fmt.Errorf("error deleting documents: %s", string(bodyBuf))
+	}
+	return nil
+}
+
+func (c Collection) Get(ids []string, where map[string]any, documents map[string]any) ([]Document, error) {
+	return c.GetContext(context.Background(), ids, where, documents)
+}
+
+// GetContext is Get with a context used for the request
+func (c Collection) GetContext(ctx context.Context, ids []string, where map[string]any, documents map[string]any) ([]Document, error) {
+	return c.get(ctx, map[string]any{
+		"ids":            ids,
+		"where":          where,
+		"where_document": documents,
+	})
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// get sends payload to the collection's get endpoint and returns the documents found
+func (c Collection) get(ctx context.Context, payload map[string]any) ([]Document, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, http.MethodPost, "/get", bytes.NewReader(body), RequestInfo{Operation: OpGet})
 	if err != nil {
 		return nil, err
 	}
@@ -182,6 +259,9 @@ func (c Collection) Get(ids []string, where map[string]any, documents map[string
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting documents: %s", string(bodyBuf))
+	}
 
 	respObj := chromaCollectionObject{}
 	err = json.Unmarshal(bodyBuf, &respObj)
@@ -203,17 +283,31 @@ const (
 /*
 Query fetches results for a single query. TODO: bulk query implementation
 This calculates the embeddings for the query automatically. TODO: allow search by embeddings
+embedder may be nil for collections bound to an embedder
 */
 func (c Collection) Query(query string, numResults int32, where map[string]interface{},
 	whereDocument map[string]interface{}, include []QueryEnum, embedder embeddings.Embedder) ([]Document, error) {
+	return c.QueryContext(context.Background(), query, numResults, where, whereDocument, include, embedder)
+}
+
+// QueryContext is Query with a context used for embedding the query and sending the request
+func (c Collection) QueryContext(ctx context.Context, query string, numResults int32, where map[string]interface{},
+	whereDocument map[string]interface{}, include []QueryEnum, embedder embeddings.Embedder) ([]Document, error) {
 
 	if len(include) == 0 {
 		include = []QueryEnum{WithDocuments, WithEmbeddings, WithDistances, WithMetadatas}
 	}
-	queryEmbeddings, err := embedder.EmbedQuery(context.Background(), query)
+	embedder, err := c.resolveEmbedder(embedder)
+	if err != nil {
+		return nil, err
+	}
+	queryEmbeddings, err := embedder.EmbedQuery(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("error generating embeddings for query. Error: %w", err)
 	}
+	if err = c.checkDimension(queryEmbeddings); err != nil {
+		return nil, err
+	}
 	payload := map[string]any{
 		"query_embeddings": [][]float32{queryEmbeddings},
 		"query_texts":      query,
@@ -227,15 +321,7 @@ func (c Collection) Query(query string, numResults int32, where map[string]inter
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest(
-		http.MethodPost,
-		c.server.BaseUrl()+"/collections/"+c.ID+"/query",
-		bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.send(ctx, http.MethodPost, "/query", bytes.NewReader(body), RequestInfo{Operation: OpQuery})
 	if err != nil {
 		return nil, err
 	}
@@ -255,7 +341,12 @@ func (c Collection) Query(query string, numResults int32, where map[string]inter
 }
 
 func (c Collection) Count() (int, error) {
-	resp, err := http.DefaultClient.Get(c.server.BaseUrl() + "/collections/" + c.ID + "/count")
+	return c.CountContext(context.Background())
+}
+
+// CountContext is Count with a context used for the request
+func (c Collection) CountContext(ctx context.Context) (int, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/count", nil, RequestInfo{Operation: OpCount})
 	if err != nil {
 		return -1, err
 	}
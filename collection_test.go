@@ -189,6 +189,26 @@ var _ = Describe("Collection", func() {
 
 	})
 
+	It("adds documents with and without embeddings together", func() {
+		testClient.DeleteCollection("collections-mixed-unit-test")
+		tc, err := testClient.CreateCollection("collections-mixed-unit-test", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		err = tc.Add([]chroma.Document{
+			{ID: "embedded1", Content: "a", Embeddings: []float32{1, 1.1, 2.2}},
+			{ID: "embedded2", Content: "b", Embeddings: []float32{2, 1.1, 2.2}},
+			testDocument1,
+		}, testEmbedder{})
+		Expect(err).ToNot(HaveOccurred())
+
+		docs, err := tc.Get(nil, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		var ids []string
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		Expect(ids).To(ConsistOf("embedded1", "embedded2", "testDoc1"))
+	})
+
 	It("Slice batcher", func() {
 		flatten := func(nested [][]int) []int {
 			var res []int
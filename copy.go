@@ -0,0 +1,74 @@
+package chroma
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+// CopyOptions configures CopyCollection
+type CopyOptions struct {
+	// Name is the name of the destination collection. Defaults to the source's name
+	Name string
+	// BatchSize is the number of documents fetched and written per request. Defaults to 100
+	BatchSize int
+	// Where and WhereDocument only copy the matching documents
+	Where         map[string]any
+	WhereDocument map[string]any
+	// Embedder re-embeds every document instead of copying its embedding. The source's embedder
+	// metadata is then dropped, give WithRegisteredEmbedder in CollectionOptions to record the new one
+	Embedder embeddings.Embedder
+	// CollectionOptions are used to create the destination collection
+	CollectionOptions []CollectionOption
+	// TransformMetadata returns the metadata written for doc in place of doc.Metadata
+	TransformMetadata func(doc Document) map[string]any
+	// Progress is called after every batch written with the number of documents copied so far
+	Progress func(copied int)
+}
+
+// CopyCollection creates a collection on dst with the metadata and distance function of src and
+// upserts every document of src into it in batches. It returns the destination collection and the
+// number of documents copied. It fails if the destination collection already exists
+func CopyCollection(ctx context.Context, src Collection, dst Chroma, opts CopyOptions) (Collection, int, error) {
+	if opts.Name == "" {
+		opts.Name = src.Name
+	}
+	metadata := copyMetadata(src.Metadata)
+	if opts.Embedder != nil {
+		delete(metadata, MetadataEmbedderName)
+		delete(metadata, MetadataEmbedderModel)
+		delete(metadata, MetadataEmbedderDimension)
+	}
+	target, err := dst.CreateCollection(opts.Name, src.DistanceFn, metadata, opts.CollectionOptions...)
+	if err != nil {
+		return Collection{}, 0, fmt.Errorf("creating collection %s: %w", opts.Name, err)
+	}
+
+	include := []QueryEnum{WithDocuments, WithMetadatas, WithEmbeddings}
+	if opts.Embedder != nil {
+		include = []QueryEnum{WithDocuments, WithMetadatas}
+	}
+	copied := 0
+	err = src.Scan(ctx, ScanOptions{
+		BatchSize: opts.BatchSize, Where: opts.Where, WhereDocument: opts.WhereDocument, Include: include,
+	}, func(docs []Document) error {
+		if opts.TransformMetadata != nil {
+			for i := range docs {
+				docs[i].Metadata = opts.TransformMetadata(docs[i])
+			}
+		}
+		if err := target.UpsertContext(ctx, docs, opts.Embedder); err != nil {
+			return err
+		}
+		copied += len(docs)
+		if opts.Progress != nil {
+			opts.Progress(copied)
+		}
+		return nil
+	})
+	if err != nil {
+		return target, copied, fmt.Errorf("copying %s after %d documents: %w", src.Name, copied, err)
+	}
+	return target, copied, nil
+}
@@ -0,0 +1,79 @@
+package chroma_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+var _ = Describe("CopyCollection", func() {
+	var source, target *fakeChroma
+	var src chroma.Collection
+	var dst chroma.Chroma
+
+	BeforeEach(func() {
+		source = newFakeChroma()
+		target = newFakeChroma()
+		client, err := chroma.NewClient(source.URL)
+		Expect(err).ToNot(HaveOccurred())
+		src = seedCollection(client, "docs", 5)
+		dst, err = chroma.NewClient(target.URL)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		source.Close()
+		target.Close()
+	})
+
+	It("creates the destination like the source and copies every document in batches", func() {
+		var progress []int
+		copied, n, err := chroma.CopyCollection(context.Background(), src, dst,
+			chroma.CopyOptions{BatchSize: 2, Progress: func(copied int) { progress = append(progress, copied) }})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(5))
+		Expect(progress).To(Equal([]int{2, 4, 5}))
+		Expect(copied.Name).To(Equal("docs"))
+		Expect(copied.DistanceFn).To(Equal("cosine"))
+		Expect(copied.Metadata).To(HaveKeyWithValue("team", "search"))
+
+		docs, err := copied.Get(nil, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(docs).To(HaveLen(5))
+		Expect(target.byName("docs").docs[3]).To(Equal(fakeDoc{
+			ID: "3", Document: "doc 3", Metadata: map[string]any{"n": 3.0, "even": false}, Embedding: []float32{3, 1},
+		}))
+	})
+
+	It("re-embeds documents and transforms their metadata", func() {
+		embedder := embeddings.NewHashEmbedder(4)
+		copied, n, err := chroma.CopyCollection(context.Background(), src, dst, chroma.CopyOptions{
+			Name:     "docs-v2",
+			Embedder: embedder,
+			Where:    map[string]any{"n": map[string]any{"$in": []any{1, 2}}},
+			TransformMetadata: func(doc chroma.Document) map[string]any {
+				return map[string]any{"n": doc.Metadata["n"], "copied": true}
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(2))
+		Expect(copied.Name).To(Equal("docs-v2"))
+
+		expected, err := embedder.EmbedDocuments(context.Background(), []string{"doc 1"})
+		Expect(err).ToNot(HaveOccurred())
+		docs := target.byName("docs-v2").docs
+		Expect(docs).To(HaveLen(2))
+		Expect(docs[0].Embedding).To(Equal(expected[0]))
+		Expect(docs[0].Metadata).To(Equal(map[string]any{"n": 1.0, "copied": true}))
+	})
+
+	It("fails when the destination exists", func() {
+		_, err := dst.CreateCollection("docs", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, _, err = chroma.CopyCollection(context.Background(), src, dst, chroma.CopyOptions{})
+		Expect(err).To(MatchError(ContainSubstring("creating collection docs")))
+	})
+})
@@ -0,0 +1,186 @@
+package chroma
+
+import (
+	"context"
+	"reflect"
+)
+
+// DiffKind is the kind of a difference between two collections
+type DiffKind int
+
+const (
+	// DiffMissing documents are in the first collection only
+	DiffMissing DiffKind = iota
+	// DiffExtra documents are in the second collection only
+	DiffExtra
+	// DiffChanged documents are in both collections with different fields
+	DiffChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffMissing:
+		return "missing"
+	case DiffExtra:
+		return "extra"
+	case DiffChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// fields of documents compared by DiffCollections
+const (
+	ContentField   = "content"
+	MetadataField  = "metadata"
+	EmbeddingField = "embedding"
+)
+
+// Difference is a document that differs between two collections
+type Difference struct {
+	ID   string
+	Kind DiffKind
+	// Fields lists the fields of changed documents that differ, like ContentField
+	Fields []string
+}
+
+// DiffOptions configures DiffCollections
+type DiffOptions struct {
+	// BatchSize is the number of documents fetched per request. Defaults to 100
+	BatchSize int
+	// Tolerance is the largest difference between two embedding values considered equal
+	Tolerance float32
+	// SkipEmbeddings doesn't compare embeddings, like for collections embedded by different models
+	SkipEmbeddings bool
+	// OnDifference is called with every difference found instead of collecting them in the report,
+	// for collections with more differences than fit in memory. An error stops the diff
+	OnDifference func(d Difference) error
+}
+
+// DiffReport summarizes the differences between two collections
+type DiffReport struct {
+	Compared int // documents of the first collection
+	Missing  int
+	Extra    int
+	Changed  int
+	// Differences lists every difference found, unless DiffOptions.OnDifference is set
+	Differences []Difference
+}
+
+// Equal reports whether the collections hold the same documents
+func (r DiffReport) Equal() bool {
+	return r.Missing == 0 && r.Extra == 0 && r.Changed == 0
+}
+
+// DiffCollections compares the documents of a and b by id, content, metadata and embedding. It
+// scans a in pages and fetches the same ids from b, then scans the ids of b to find the documents
+// missing from a, so only a page of each collection is held in memory. Documents written while the
+// diff runs may be reported as differences
+func DiffCollections(ctx context.Context, a, b Collection, opts DiffOptions) (DiffReport, error) {
+	report := DiffReport{}
+	found := func(d Difference) error {
+		switch d.Kind {
+		case DiffMissing:
+			report.Missing++
+		case DiffExtra:
+			report.Extra++
+		case DiffChanged:
+			report.Changed++
+		}
+		if opts.OnDifference != nil {
+			return opts.OnDifference(d)
+		}
+		report.Differences = append(report.Differences, d)
+		return nil
+	}
+
+	include := []QueryEnum{WithDocuments, WithMetadatas, WithEmbeddings}
+	if opts.SkipEmbeddings {
+		include = []QueryEnum{WithDocuments, WithMetadatas}
+	}
+	err := a.Scan(ctx, ScanOptions{BatchSize: opts.BatchSize, Include: include}, func(docs []Document) error {
+		report.Compared += len(docs)
+		others, err := b.get(ctx, map[string]any{"ids": documentIDs(docs), "include": include})
+		if err != nil {
+			return err
+		}
+		byID := make(map[string]Document, len(others))
+		for _, other := range others {
+			byID[other.ID] = other
+		}
+		for _, doc := range docs {
+			other, ok := byID[doc.ID]
+			if !ok {
+				if err := found(Difference{ID: doc.ID, Kind: DiffMissing}); err != nil {
+					return err
+				}
+				continue
+			}
+			if fields := changedFields(doc, other, opts); len(fields) > 0 {
+				if err := found(Difference{ID: doc.ID, Kind: DiffChanged, Fields: fields}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	err = b.Scan(ctx, ScanOptions{BatchSize: opts.BatchSize, Include: []QueryEnum{}}, func(docs []Document) error {
+		others, err := a.get(ctx, map[string]any{"ids": documentIDs(docs), "include": []QueryEnum{}})
+		if err != nil {
+			return err
+		}
+		inA := make(map[string]bool, len(others))
+		for _, other := range others {
+			inA[other.ID] = true
+		}
+		for _, doc := range docs {
+			if !inA[doc.ID] {
+				if err := found(Difference{ID: doc.ID, Kind: DiffExtra}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+func documentIDs(docs []Document) []string {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids
+}
+
+// changedFields returns the fields that differ between two versions of a document
+func changedFields(a, b Document, opts DiffOptions) []string {
+	var fields []string
+	if a.Content != b.Content {
+		fields = append(fields, ContentField)
+	}
+	// metadata maps empty or nil alike
+	if (len(a.Metadata) > 0 || len(b.Metadata) > 0) && !reflect.DeepEqual(a.Metadata, b.Metadata) {
+		fields = append(fields, MetadataField)
+	}
+	if !opts.SkipEmbeddings && !embeddingsEqual(a.Embeddings, b.Embeddings, opts.Tolerance) {
+		fields = append(fields, EmbeddingField)
+	}
+	return fields
+}
+
+func embeddingsEqual(a, b []float32, tolerance float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if d := a[i] - b[i]; d > tolerance || d < -tolerance {
+			return false
+		}
+	}
+	return true
+}
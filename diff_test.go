@@ -0,0 +1,96 @@
+package chroma_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+var _ = Describe("DiffCollections", func() {
+	var server *fakeChroma
+	var a, b chroma.Collection
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+		client, err := chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		a, err = client.CreateCollection("a", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		b, err = client.CreateCollection("b", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(a.Add([]chroma.Document{
+			{ID: "same", Content: "x", Metadata: map[string]any{"n": 1}, Embeddings: []float32{1, 2}},
+			{ID: "close", Content: "x", Embeddings: []float32{1, 2}},
+			{ID: "content", Content: "x", Embeddings: []float32{1, 2}},
+			{ID: "fields", Content: "x", Metadata: map[string]any{"n": 1}, Embeddings: []float32{1, 2}},
+			{ID: "missing", Content: "x", Embeddings: []float32{1, 2}},
+		}, nil)).To(Succeed())
+		Expect(b.Add([]chroma.Document{
+			{ID: "same", Content: "x", Metadata: map[string]any{"n": 1}, Embeddings: []float32{1, 2}},
+			{ID: "close", Content: "x", Embeddings: []float32{1.001, 2}},
+			{ID: "content", Content: "y", Embeddings: []float32{1, 2}},
+			{ID: "fields", Content: "x", Metadata: map[string]any{"n": 2}, Embeddings: []float32{1, 3}},
+			{ID: "extra", Content: "x", Embeddings: []float32{1, 2}},
+		}, nil)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("reports missing, extra and changed documents in pages", func() {
+		report, err := chroma.DiffCollections(context.Background(), a, b,
+			chroma.DiffOptions{BatchSize: 2, Tolerance: 0.01})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Equal()).To(BeFalse())
+		Expect(report).To(Equal(chroma.DiffReport{
+			Compared: 5, Missing: 1, Extra: 1, Changed: 2,
+			Differences: []chroma.Difference{
+				{ID: "content", Kind: chroma.DiffChanged, Fields: []string{chroma.ContentField}},
+				{ID: "fields", Kind: chroma.DiffChanged, Fields: []string{chroma.MetadataField, chroma.EmbeddingField}},
+				{ID: "missing", Kind: chroma.DiffMissing},
+				{ID: "extra", Kind: chroma.DiffExtra},
+			},
+		}))
+	})
+
+	It("compares embeddings exactly without tolerance and can skip them", func() {
+		report, err := chroma.DiffCollections(context.Background(), a, b, chroma.DiffOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Differences).To(ContainElement(
+			chroma.Difference{ID: "close", Kind: chroma.DiffChanged, Fields: []string{chroma.EmbeddingField}}))
+
+		report, err = chroma.DiffCollections(context.Background(), a, b, chroma.DiffOptions{SkipEmbeddings: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Differences).To(ContainElement(
+			chroma.Difference{ID: "fields", Kind: chroma.DiffChanged, Fields: []string{chroma.MetadataField}}))
+		Expect(report.Changed).To(Equal(2))
+	})
+
+	It("streams differences to a callback", func() {
+		var kinds []string
+		report, err := chroma.DiffCollections(context.Background(), a, b, chroma.DiffOptions{
+			Tolerance:    0.01,
+			OnDifference: func(d chroma.Difference) error { kinds = append(kinds, d.Kind.String()); return nil },
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Differences).To(BeEmpty())
+		Expect(kinds).To(Equal([]string{"changed", "changed", "missing", "extra"}))
+
+		stop := errors.New("stop")
+		_, err = chroma.DiffCollections(context.Background(), a, b, chroma.DiffOptions{
+			OnDifference: func(chroma.Difference) error { return stop },
+		})
+		Expect(err).To(MatchError(stop))
+	})
+
+	It("reports equal collections", func() {
+		report, err := chroma.DiffCollections(context.Background(), a, a, chroma.DiffOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Equal()).To(BeTrue())
+		Expect(report.Compared).To(Equal(5))
+	})
+})
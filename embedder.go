@@ -0,0 +1,180 @@
+package chroma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+// collection metadata keys recording the embedder that produced the collection's vectors
+const (
+	MetadataEmbedderName      = "embedder:name"
+	MetadataEmbedderModel     = "embedder:model"
+	MetadataEmbedderDimension = "embedder:dimension"
+)
+
+// ErrEmbedderMismatch is returned when a call uses an embedder that doesn't match the one
+// recorded in the collection's metadata
+var ErrEmbedderMismatch = errors.New("embedder does not match the collection")
+
+// EmbedderInfo describes the embedder recorded in a collection's metadata
+type EmbedderInfo struct {
+	Name      string
+	Model     string
+	Dimension int
+}
+
+// EmbedderInfo returns the embedder recorded in the collection's metadata, if any
+func (c Collection) EmbedderInfo() (EmbedderInfo, bool) {
+	name, ok := c.Metadata[MetadataEmbedderName].(string)
+	if !ok {
+		return EmbedderInfo{}, false
+	}
+	info := EmbedderInfo{Name: name}
+	info.Model, _ = c.Metadata[MetadataEmbedderModel].(string)
+	// numbers come back from the server as json floats
+	switch dim := c.Metadata[MetadataEmbedderDimension].(type) {
+	case float64:
+		info.Dimension = int(dim)
+	case int:
+		info.Dimension = dim
+	}
+	return info, true
+}
+
+type collectionOptions struct {
+	embedderName      string
+	embedderModel     string
+	embedderDimension int
+	embedder          embeddings.Embedder
+}
+
+// CollectionOption configures collections when they are created or fetched
+type CollectionOption func(*collectionOptions)
+
+// WithRegisteredEmbedder records the embedder registered under name with embeddings.Register,
+// its model and its dimension in the metadata of a newly created collection. Collections fetched
+// later on are bound to an embedder created by the same factory. Unless WithEmbedderDimension is
+// given, GetOrCreateCollection first gets the collection to reuse the dimension it records, which
+// costs a request even when the collection is then created
+func WithRegisteredEmbedder(name, model string) CollectionOption {
+	return func(o *collectionOptions) {
+		o.embedderName = name
+		o.embedderModel = model
+	}
+}
+
+// WithEmbedderDimension gives the dimension of the embedder set by WithRegisteredEmbedder, which
+// is otherwise found by embedding a probe text when the collection doesn't record it yet
+func WithEmbedderDimension(dimension int) CollectionOption {
+	return func(o *collectionOptions) {
+		o.embedderDimension = dimension
+	}
+}
+
+// WithEmbedder binds the collection to embedder, so Add and Query calls can pass a nil embedder.
+// The embedder must match the one recorded in the collection's metadata, if any
+func WithEmbedder(embedder embeddings.Embedder) CollectionOption {
+	return func(o *collectionOptions) {
+		o.embedder = embedder
+	}
+}
+
+// WithEmbedder returns a copy of the collection bound to embedder, which is used whenever
+// Add and Query calls pass a nil embedder
+func (c Collection) WithEmbedder(embedder embeddings.Embedder) Collection {
+	c.embedder = embedder
+	return c
+}
+
+func applyCollectionOptions(opts []CollectionOption) collectionOptions {
+	o := collectionOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// embedderMetadata creates the registered embedder selected by o and returns it along with the
+// metadata recording it. Unless given, the dimension is found by embedding a probe text
+func (o collectionOptions) embedderMetadata() (embeddings.Embedder, map[string]any, error) {
+	if o.embedderName == "" {
+		return nil, nil, nil
+	}
+	embedder, err := embeddings.New(o.embedderName, o.embedderModel)
+	if err != nil {
+		return nil, nil, err
+	}
+	dimension := o.embedderDimension
+	if dimension == 0 {
+		probe, err := embedder.EmbedQuery(context.Background(), "dimension probe")
+		if err != nil {
+			return nil, nil, fmt.Errorf("error finding embedder dimension: %w", err)
+		}
+		dimension = len(probe)
+	}
+	return embedder, map[string]any{
+		MetadataEmbedderName:      o.embedderName,
+		MetadataEmbedderModel:     o.embedderModel,
+		MetadataEmbedderDimension: dimension,
+	}, nil
+}
+
+// bindEmbedder binds the collection to explicit if given, else to the embedder recorded in its
+// metadata and else to fallback when it matches the recorded embedder.
+// Recorded embedders that aren't registered in this process are not bound
+func (c *Collection) bindEmbedder(explicit, fallback embeddings.Embedder) {
+	var registered embeddings.Embedder
+	if info, ok := c.EmbedderInfo(); ok {
+		c.expectedModel = info.Model
+		registered, _ = embeddings.New(info.Name, info.Model)
+		// factories may name their model differently than the model they were asked for
+		if m, ok := registered.(embeddings.ModelNamer); ok {
+			c.expectedModel = m.Model()
+		}
+	}
+	switch {
+	case explicit != nil:
+		c.embedder = explicit
+	case registered != nil:
+		c.embedder = registered
+	case fallback != nil && c.checkModel(fallback) == nil:
+		c.embedder = fallback
+	}
+}
+
+// resolveEmbedder returns the embedder to use for a call: the given one or else the one bound to
+// the collection. It errors if the embedder reports a different model than the collection records
+func (c Collection) resolveEmbedder(embedder embeddings.Embedder) (embeddings.Embedder, error) {
+	if embedder == nil {
+		embedder = c.embedder
+	}
+	if embedder == nil {
+		return nil, fmt.Errorf("no embedder given and none bound to collection %s", c.Name)
+	}
+	if err := c.checkModel(embedder); err != nil {
+		return nil, err
+	}
+	return embedder, nil
+}
+
+// checkModel errors if embedder reports a different model than the collection records
+func (c Collection) checkModel(embedder embeddings.Embedder) error {
+	m, ok := embedder.(embeddings.ModelNamer)
+	if !ok || c.expectedModel == "" || m.Model() == c.expectedModel {
+		return nil
+	}
+	return fmt.Errorf("%w: collection %s was embedded with model %s, got %s",
+		ErrEmbedderMismatch, c.Name, c.expectedModel, m.Model())
+}
+
+// checkDimension errors if vector doesn't have the dimension recorded in the collection's metadata
+func (c Collection) checkDimension(vector []float32) error {
+	info, ok := c.EmbedderInfo()
+	if !ok || info.Dimension == 0 || len(vector) == info.Dimension {
+		return nil
+	}
+	return fmt.Errorf("%w: collection %s has dimension %d, got a vector of dimension %d",
+		ErrEmbedderMismatch, c.Name, info.Dimension, len(vector))
+}
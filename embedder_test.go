@@ -0,0 +1,194 @@
+package chroma_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+var _ = Describe("Collection embedders", func() {
+	var server *fakeChroma
+	var client chroma.Chroma
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+		c, err := chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		client = c
+
+		// "hash" embedders take their dimension as model name
+		embeddings.Register("hash", func(model string) (embeddings.Embedder, error) {
+			dim, err := strconv.Atoi(model)
+			if err != nil {
+				return nil, err
+			}
+			return embeddings.NewHashEmbedder(dim), nil
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("lists registered embedders", func() {
+		Expect(embeddings.Registered()).To(ContainElement("hash"))
+		_, err := embeddings.New("unknown", "")
+		Expect(err).To(MatchError(ContainSubstring(`no embedder registered with name "unknown"`)))
+	})
+
+	It("records the embedder in collection metadata and binds it on get", func() {
+		created, err := client.CreateCollection("registry", "cosine", nil,
+			chroma.WithRegisteredEmbedder("hash", "16"))
+		Expect(err).ToNot(HaveOccurred())
+		info, ok := created.EmbedderInfo()
+		Expect(ok).To(BeTrue())
+		Expect(info).To(Equal(chroma.EmbedderInfo{Name: "hash", Model: "16", Dimension: 16}))
+
+		// no embedder passed, the bound one is used
+		Expect(created.Add([]chroma.Document{{ID: "1", Content: "hello world"}}, nil)).To(Succeed())
+
+		collection, err := client.GetCollection("registry")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.DistanceFn).To(Equal("cosine"))
+		info, ok = collection.EmbedderInfo()
+		Expect(ok).To(BeTrue())
+		Expect(info.Dimension).To(Equal(16))
+
+		docs, err := collection.Query("hello", 1, nil, nil, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(docs).To(HaveLen(1))
+		Expect(docs[0].ID).To(Equal("1"))
+
+		collections, err := client.ListCollections()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collections).To(HaveLen(1))
+		Expect(collections[0].Add([]chroma.Document{{ID: "2", Content: "bound"}}, nil)).To(Succeed())
+	})
+
+	It("only probes the embedder dimension when it isn't known", func() {
+		probes := 0
+		embeddings.Register("counted", func(model string) (embeddings.Embedder, error) {
+			return embeddings.Chain(embeddings.NewHashEmbedder(8),
+				embeddings.Instrument(func(context.Context, embeddings.EmbedCall) { probes++ })), nil
+		})
+		for i := 0; i < 2; i++ {
+			collection, err := client.GetOrCreateCollection("counted", "l2", nil,
+				chroma.WithRegisteredEmbedder("counted", "v1"))
+			Expect(err).ToNot(HaveOccurred())
+			info, _ := collection.EmbedderInfo()
+			Expect(info.Dimension).To(Equal(8))
+		}
+		Expect(probes).To(Equal(1))
+
+		collection, err := client.CreateCollection("given", "l2", nil,
+			chroma.WithRegisteredEmbedder("counted", "v1"), chroma.WithEmbedderDimension(8))
+		Expect(err).ToNot(HaveOccurred())
+		info, _ := collection.EmbedderInfo()
+		Expect(info.Dimension).To(Equal(8))
+		Expect(probes).To(Equal(1))
+	})
+
+	It("refuses mismatched embedders", func() {
+		collection, err := client.CreateCollection("registry", "l2", nil,
+			chroma.WithRegisteredEmbedder("hash", "16"))
+		Expect(err).ToNot(HaveOccurred())
+
+		err = collection.Add([]chroma.Document{{ID: "1", Content: "hello"}}, embeddings.NewHashEmbedder(8))
+		Expect(err).To(MatchError(chroma.ErrEmbedderMismatch))
+		_, err = collection.Query("hello", 1, nil, nil, nil, embeddings.NewRandomEmbedder(16, 1))
+		Expect(err).To(MatchError(chroma.ErrEmbedderMismatch))
+
+		// embedders that don't report a model are still checked by dimension
+		_, err = collection.Query("hello", 1, nil, nil, nil, testEmbedder{})
+		Expect(err).To(MatchError(chroma.ErrEmbedderMismatch))
+		err = collection.Add([]chroma.Document{{ID: "1", Embeddings: []float32{1, 2}}}, nil)
+		Expect(err).To(MatchError(chroma.ErrEmbedderMismatch))
+
+		_, err = client.GetOrCreateCollection("registry", "l2", nil, chroma.WithRegisteredEmbedder("hash", "8"))
+		Expect(err).To(MatchError(chroma.ErrEmbedderMismatch))
+	})
+
+	It("requires an embedder for unbound collections", func() {
+		collection, err := client.CreateCollection("plain", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		err = collection.Add([]chroma.Document{{ID: "1", Content: "hello"}}, nil)
+		Expect(err).To(MatchError(ContainSubstring("no embedder given")))
+
+		// documents that come with embeddings don't need one
+		err = collection.Add([]chroma.Document{{ID: "1", Embeddings: []float32{1, 2}}}, nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("errors getting unknown collections", func() {
+		_, err := client.GetCollection("unknown")
+		Expect(err).To(HaveOccurred())
+		Expect(strings.Contains(err.Error(), "does not exist")).To(BeTrue())
+	})
+
+	Describe("bound embedders", func() {
+		It("binds collections to an embedder with per call overrides", func() {
+			_, err := client.CreateCollection("bound", "l2", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			inner := &recordingEmbedder{}
+			collection, err := client.GetCollection("bound", chroma.WithEmbedder(inner))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(collection.Add([]chroma.Document{{ID: "1", Content: "abc"}}, nil)).To(Succeed())
+			_, err = collection.Query("ab", 1, nil, nil, nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inner.batches).To(Equal([][]string{{"abc"}, {"ab"}}))
+
+			override := &recordingEmbedder{}
+			_, err = collection.Query("a", 1, nil, nil, nil, override)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(override.batches).To(Equal([][]string{{"a"}}))
+			Expect(inner.batches).To(HaveLen(2))
+
+			rebound := &recordingEmbedder{}
+			Expect(collection.WithEmbedder(rebound).Add([]chroma.Document{{ID: "2", Content: "d"}}, nil)).To(Succeed())
+			Expect(rebound.batches).To(Equal([][]string{{"d"}}))
+			Expect(inner.batches).To(HaveLen(2))
+		})
+
+		It("binds every collection to the client default embedder", func() {
+			defaultEmbedder := &recordingEmbedder{}
+			c, err := chroma.NewClient(server.URL, chroma.WithDefaultEmbedder(defaultEmbedder))
+			Expect(err).ToNot(HaveOccurred())
+
+			created, err := c.CreateCollection("default", "l2", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created.Add([]chroma.Document{{ID: "1", Content: "abc"}}, nil)).To(Succeed())
+			fetched, err := c.GetCollection("default")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = fetched.Query("abc", 1, nil, nil, nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			listed, err := c.ListCollections()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(listed[0].Add([]chroma.Document{{ID: "2", Content: "d"}}, nil)).To(Succeed())
+			Expect(defaultEmbedder.batches).To(Equal([][]string{{"abc"}, {"abc"}, {"d"}}))
+
+			// collections recording another embedder keep it
+			recorded, err := c.CreateCollection("recorded", "l2", nil, chroma.WithRegisteredEmbedder("hash", "4"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorded.Add([]chroma.Document{{ID: "1", Content: "abc"}}, nil)).To(Succeed())
+			Expect(defaultEmbedder.batches).To(HaveLen(3))
+		})
+
+		It("refuses binding embedders that don't match the collection", func() {
+			_, err := client.CreateCollection("recorded", "l2", nil, chroma.WithRegisteredEmbedder("hash", "4"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.GetCollection("recorded", chroma.WithEmbedder(embeddings.NewHashEmbedder(8)))
+			Expect(err).To(MatchError(chroma.ErrEmbedderMismatch))
+
+			collection, err := client.GetCollection("recorded")
+			Expect(err).ToNot(HaveOccurred())
+			err = collection.WithEmbedder(embeddings.NewHashEmbedder(8)).Add([]chroma.Document{{ID: "1", Content: "a"}}, nil)
+			Expect(err).To(MatchError(chroma.ErrEmbedderMismatch))
+		})
+	})
+})
@@ -0,0 +1,258 @@
+package embeddings
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStore persists embedding vectors by key for a CachingEmbedder
+type CacheStore interface {
+	// Get returns the vector stored for key and whether it was found
+	Get(key string) ([]float32, bool, error)
+	Set(key string, vector []float32) error
+}
+
+const (
+	cacheKindDocument = "document"
+	cacheKindQuery    = "query"
+)
+
+// CachingEmbedder only forwards texts it hasn't seen before to the wrapped embedder.
+// Vectors are cached by model, by whether the text was embedded as a document or a query
+// (some providers embed those differently) and by a hash of the text
+type CachingEmbedder struct {
+	inner Embedder
+	store CacheStore
+	model string
+}
+
+// CachingOption configures a CachingEmbedder
+type CachingOption func(*CachingEmbedder)
+
+// WithCacheModel overrides the model used in cache keys. By default the model reported by the
+// wrapped embedder is used when it implements ModelNamer
+func WithCacheModel(model string) CachingOption {
+	return func(c *CachingEmbedder) {
+		c.model = model
+	}
+}
+
+func NewCachingEmbedder(inner Embedder, store CacheStore, opts ...CachingOption) *CachingEmbedder {
+	c := &CachingEmbedder{inner: inner, store: store, model: modelOf(inner)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *CachingEmbedder) Model() string {
+	return c.model
+}
+
+func (c *CachingEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	key := c.key(cacheKindQuery, content)
+	vector, ok, err := c.store.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading embedding cache: %w", err)
+	}
+	if ok {
+		return vector, nil
+	}
+	vector, err = c.inner.EmbedQuery(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.store.Set(key, vector); err != nil {
+		return nil, fmt.Errorf("error writing embedding cache: %w", err)
+	}
+	return vector, nil
+}
+
+func (c *CachingEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(content))
+	keys := make([]string, len(content))
+
+	// positions of every uncached text, so duplicates in one batch are only embedded once
+	missing := map[string][]int{}
+	var missingTexts []string
+	for i, text := range content {
+		keys[i] = c.key(cacheKindDocument, text)
+		if _, seen := missing[keys[i]]; seen {
+			missing[keys[i]] = append(missing[keys[i]], i)
+			continue
+		}
+		vector, ok, err := c.store.Get(keys[i])
+		if err != nil {
+			return nil, fmt.Errorf("error reading embedding cache: %w", err)
+		}
+		if ok {
+			embeddings[i] = vector
+			continue
+		}
+		missing[keys[i]] = []int{i}
+		missingTexts = append(missingTexts, text)
+	}
+	if len(missingTexts) == 0 {
+		return embeddings, nil
+	}
+
+	vectors, err := c.inner.EmbedDocuments(ctx, missingTexts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(missingTexts) {
+		return nil, fmt.Errorf("embedder returned %d embeddings for %d documents", len(vectors), len(missingTexts))
+	}
+	for i, text := range missingTexts {
+		key := c.key(cacheKindDocument, text)
+		if err = c.store.Set(key, vectors[i]); err != nil {
+			return nil, fmt.Errorf("error writing embedding cache: %w", err)
+		}
+		for _, pos := range missing[key] {
+			embeddings[pos] = vectors[i]
+		}
+	}
+	return embeddings, nil
+}
+
+func (c *CachingEmbedder) key(kind, content string) string {
+	h := sha256.New()
+	h.Write([]byte(c.model))
+	h.Write([]byte{0})
+	h.Write([]byte(kind))
+	h.Write([]byte{0})
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LRUCacheStore is an in-memory CacheStore holding at most capacity vectors,
+// evicting the least recently used ones first
+type LRUCacheStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key    string
+	vector []float32
+}
+
+func NewLRUCacheStore(capacity int) *LRUCacheStore {
+	return &LRUCacheStore{
+		capacity: capacity,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (l *LRUCacheStore) Get(key string) ([]float32, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	l.order.MoveToFront(el)
+	// copies keep callers changing vectors in place from changing the cache
+	return append([]float32(nil), el.Value.(*lruEntry).vector...), true, nil
+}
+
+func (l *LRUCacheStore) Set(key string, vector []float32) error {
+	vector = append([]float32(nil), vector...)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		el.Value.(*lruEntry).vector = vector
+		l.order.MoveToFront(el)
+		return nil
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, vector: vector})
+	for l.capacity > 0 && l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+// Len returns the number of cached vectors
+func (l *LRUCacheStore) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// DiskCacheStore is a CacheStore keeping one little-endian float32 file per vector in a directory.
+// Files are sharded into sub directories by key prefix and written atomically
+type DiskCacheStore struct {
+	dir string
+}
+
+func NewDiskCacheStore(dir string) (*DiskCacheStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating embedding cache dir: %w", err)
+	}
+	return &DiskCacheStore{dir: dir}, nil
+}
+
+func (d *DiskCacheStore) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(d.dir, key)
+	}
+	return filepath.Join(d.dir, key[:2], key)
+}
+
+func (d *DiskCacheStore) Get(key string) ([]float32, bool, error) {
+	buf, err := os.ReadFile(d.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(buf)%4 != 0 {
+		return nil, false, fmt.Errorf("corrupt embedding cache entry %s", key)
+	}
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return vector, true, nil
+}
+
+func (d *DiskCacheStore) Set(key string, vector []float32) error {
+	buf := make([]byte, len(vector)*4)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+
+	p := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
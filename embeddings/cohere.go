@@ -0,0 +1,143 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/urjitbhatia/gochroma/internal/logging"
+)
+
+var cohereURL = "https://api.cohere.ai/v1"
+var cohereEmbedPath = "/embed"
+
+// Cohere input types, sent depending on whether documents or a query are being embedded
+const (
+	CohereInputSearchDocument = "search_document"
+	CohereInputSearchQuery    = "search_query"
+)
+
+type cohereEmbedResponse struct {
+	ID         string      `json:"id,omitempty"`
+	Embeddings [][]float32 `json:"embeddings"`
+	Meta       struct {
+		BilledUnits struct {
+			InputTokens int `json:"input_tokens,omitempty"`
+		} `json:"billed_units"`
+	} `json:"meta"`
+}
+
+// CohereClient generates embeddings using the Cohere embed API. Documents are embedded with
+// input_type=search_document and queries with input_type=search_query
+type CohereClient struct {
+	client         *http.Client
+	authHeader     string
+	cohereEndpoint string
+	model          string
+	truncate       string
+	usageTracker   UsageTracker
+	logger         *slog.Logger
+}
+
+// CohereOption configures a CohereClient
+type CohereOption func(*CohereClient)
+
+// WithCohereModel sets the embedding model. Defaults to embed-english-v3.0
+func WithCohereModel(model string) CohereOption {
+	return func(c *CohereClient) {
+		c.model = model
+	}
+}
+
+// WithCohereTruncate sets how inputs longer than the model's max length are handled:
+// "NONE", "START" or "END". Defaults to the server side default (END)
+func WithCohereTruncate(truncate string) CohereOption {
+	return func(c *CohereClient) {
+		c.truncate = truncate
+	}
+}
+
+// WithCohereUsageTracker reports the tokens billed for every request to tracker
+func WithCohereUsageTracker(tracker UsageTracker) CohereOption {
+	return func(c *CohereClient) {
+		c.usageTracker = tracker
+	}
+}
+
+// WithCohereEndpoint overrides the Cohere api base url
+func WithCohereEndpoint(endpoint string) CohereOption {
+	return func(c *CohereClient) {
+		c.cohereEndpoint = endpoint
+	}
+}
+
+// WithCohereLogger logs every request with handler
+func WithCohereLogger(handler slog.Handler) CohereOption {
+	return func(c *CohereClient) {
+		c.logger = slog.New(handler)
+	}
+}
+
+// WithCohereHTTPClient overrides the http client used to talk to Cohere
+func WithCohereHTTPClient(client *http.Client) CohereOption {
+	return func(c *CohereClient) {
+		c.client = client
+	}
+}
+
+func NewCohereClient(key string, opts ...CohereOption) CohereClient {
+	c := CohereClient{
+		client:         http.DefaultClient,
+		logger:         logging.Discard,
+		authHeader:     fmt.Sprintf("Bearer %s", key),
+		cohereEndpoint: cohereURL,
+		model:          "embed-english-v3.0",
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+func (c *CohereClient) Model() string {
+	return c.model
+}
+
+func (c *CohereClient) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	embeddings, err := c.embed(ctx, []string{content}, CohereInputSearchQuery)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (c *CohereClient) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	return c.embed(ctx, content, CohereInputSearchDocument)
+}
+
+func (c *CohereClient) embed(ctx context.Context, content []string, inputType string) ([][]float32, error) {
+	payload := map[string]any{
+		"model":      c.model,
+		"texts":      content,
+		"input_type": inputType,
+	}
+	if c.truncate != "" {
+		payload["truncate"] = c.truncate
+	}
+
+	er := cohereEmbedResponse{}
+	start := time.Now()
+	err := postJSON(ctx, c.client, c.logger, c.cohereEndpoint+cohereEmbedPath, c.authHeader, "cohere",
+		len(content), payload, &er)
+	if err != nil {
+		return nil, err
+	}
+	if len(er.Embeddings) != len(content) {
+		return nil, fmt.Errorf("something went wrong, requested %d embeddings from cohere but got %d",
+			len(content), len(er.Embeddings))
+	}
+	trackUsage(ctx, c.usageTracker, c.model, er.Meta.BilledUnits.InputTokens, start)
+	return er.Embeddings, nil
+}
@@ -8,3 +8,16 @@ type Embedder interface {
 	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
 	EmbedQuery(ctx context.Context, text string) ([]float32, error)
 }
+
+// ModelNamer is implemented by embedders that can report which model produces their vectors
+type ModelNamer interface {
+	Model() string
+}
+
+// modelOf returns the model reported by e or an empty string if e doesn't report one
+func modelOf(e Embedder) string {
+	if m, ok := e.(ModelNamer); ok {
+		return m.Model()
+	}
+	return ""
+}
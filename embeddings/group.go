@@ -0,0 +1,321 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ProviderHealth is the health of one embedder in a FallbackEmbedder or MultiEmbedder
+type ProviderHealth struct {
+	Model               string
+	Healthy             bool
+	ConsecutiveFailures int
+	LastError           error
+}
+
+// GroupOption configures a FallbackEmbedder or MultiEmbedder
+type GroupOption func(*providerGroup)
+
+// WithFailureThreshold sets after how many consecutive failures a provider is considered
+// unhealthy and skipped. Defaults to 1
+func WithFailureThreshold(failures int) GroupOption {
+	return func(g *providerGroup) {
+		g.failureThreshold = failures
+	}
+}
+
+// WithCooldown sets how long an unhealthy provider is skipped before it is tried again. Defaults to 30s
+func WithCooldown(cooldown time.Duration) GroupOption {
+	return func(g *providerGroup) {
+		g.cooldown = cooldown
+	}
+}
+
+// WithWeights sets the relative share of calls each provider of a MultiEmbedder receives,
+// in the order the providers were given. Providers default to a weight of 1
+func WithWeights(weights ...int) GroupOption {
+	return func(g *providerGroup) {
+		for i, w := range weights {
+			if i < len(g.providers) && w > 0 {
+				g.providers[i].weight = w
+			}
+		}
+	}
+}
+
+type provider struct {
+	embedder Embedder
+	weight   int
+	current  int // smooth weighted round-robin state
+
+	failures     int
+	lastErr      error
+	ejectedUntil time.Time
+}
+
+// providerGroup tracks the health of a set of embedders and the dimension they have to agree on
+type providerGroup struct {
+	mu               sync.Mutex
+	providers        []*provider
+	failureThreshold int
+	cooldown         time.Duration
+	dimension        int
+}
+
+func newProviderGroup(embedders []Embedder, opts []GroupOption) *providerGroup {
+	g := &providerGroup{failureThreshold: 1, cooldown: 30 * time.Second}
+	for _, e := range embedders {
+		g.providers = append(g.providers, &provider{embedder: e, weight: 1})
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// ordered returns the providers to try, healthy ones first in the given order
+// followed by unhealthy ones as a last resort
+func (g *providerGroup) ordered(order []int) []*provider {
+	now := time.Now()
+	var healthy, unhealthy []*provider
+	for _, i := range order {
+		p := g.providers[i]
+		if now.Before(p.ejectedUntil) {
+			unhealthy = append(unhealthy, p)
+		} else {
+			healthy = append(healthy, p)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (g *providerGroup) record(p *provider, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err == nil {
+		p.failures = 0
+		p.lastErr = nil
+		p.ejectedUntil = time.Time{}
+		return
+	}
+	p.failures++
+	p.lastErr = err
+	// another dimension won't change by retrying, the provider is ejected right away
+	var dimensionErr DimensionError
+	if p.failures >= g.failureThreshold || errors.As(err, &dimensionErr) {
+		p.ejectedUntil = time.Now().Add(g.cooldown)
+	}
+}
+
+// checkDimension fixes the group dimension on the first vectors seen and rejects any other
+func (g *providerGroup) checkDimension(vectors ...[]float32) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, vector := range vectors {
+		if g.dimension == 0 {
+			g.dimension = len(vector)
+		}
+		if len(vector) != g.dimension {
+			return DimensionError{Expected: g.dimension, Got: len(vector)}
+		}
+	}
+	return nil
+}
+
+// model is the model of the first provider, empty for groups without providers
+func (g *providerGroup) model() string {
+	if len(g.providers) == 0 {
+		return ""
+	}
+	return modelOf(g.providers[0].embedder)
+}
+
+func (g *providerGroup) health() []ProviderHealth {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	health := make([]ProviderHealth, len(g.providers))
+	for i, p := range g.providers {
+		health[i] = ProviderHealth{
+			Model:               modelOf(p.embedder),
+			Healthy:             !now.Before(p.ejectedUntil),
+			ConsecutiveFailures: p.failures,
+			LastError:           p.lastErr,
+		}
+	}
+	return health
+}
+
+// verify embeds a probe with every provider and checks they all agree on the dimension
+func (g *providerGroup) verify(ctx context.Context) error {
+	for i, p := range g.providers {
+		vector, err := p.embedder.EmbedQuery(ctx, "dimension probe")
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			err = g.checkDimension(vector)
+		}
+		g.record(p, err)
+		if err != nil {
+			return fmt.Errorf("error verifying embedder %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// embed tries providers in order until one succeeds
+func (g *providerGroup) embed(ctx context.Context, order []int, call func(Embedder) ([][]float32, error)) ([][]float32, error) {
+	if len(order) == 0 {
+		return nil, errors.New("no embedders to embed with")
+	}
+	g.mu.Lock()
+	providers := g.ordered(order)
+	g.mu.Unlock()
+
+	var errs []error
+	for _, p := range providers {
+		vectors, err := call(p.embedder)
+		if err != nil && ctx.Err() != nil {
+			// the caller gave up, that says nothing about the provider's health
+			return nil, err
+		}
+		if err == nil {
+			// vectors of another dimension count as a failure of the provider
+			err = g.checkDimension(vectors...)
+		}
+		g.record(p, err)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return vectors, nil
+	}
+	return nil, fmt.Errorf("all embedders failed: %w", errors.Join(errs...))
+}
+
+// FallbackEmbedder sends every call to the primary embedder and falls back to the secondaries,
+// in order, when it fails. Failing embedders are skipped until their cooldown passes.
+// All embedders must produce vectors of the same dimension, which is fixed by the first vectors
+// returned. Embedders answering with another dimension are skipped like failing ones, Verify checks
+// every embedder upfront
+type FallbackEmbedder struct {
+	group *providerGroup
+}
+
+func NewFallbackEmbedder(primary Embedder, secondaries ...Embedder) *FallbackEmbedder {
+	return NewFallbackEmbedderWithOptions(append([]Embedder{primary}, secondaries...))
+}
+
+func NewFallbackEmbedderWithOptions(embedders []Embedder, opts ...GroupOption) *FallbackEmbedder {
+	return &FallbackEmbedder{group: newProviderGroup(embedders, opts)}
+}
+
+func (f *FallbackEmbedder) order() []int {
+	order := make([]int, len(f.group.providers))
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+func (f *FallbackEmbedder) Model() string {
+	return f.group.model()
+}
+
+// Verify embeds a probe text with every embedder and errors if they disagree on the dimension
+func (f *FallbackEmbedder) Verify(ctx context.Context) error {
+	return f.group.verify(ctx)
+}
+
+// Health returns the health of every embedder, in the order they were given
+func (f *FallbackEmbedder) Health() []ProviderHealth {
+	return f.group.health()
+}
+
+func (f *FallbackEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	vectors, err := f.group.embed(ctx, f.order(), func(e Embedder) ([][]float32, error) {
+		vector, err := e.EmbedQuery(ctx, content)
+		return [][]float32{vector}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (f *FallbackEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	return f.group.embed(ctx, f.order(), func(e Embedder) ([][]float32, error) {
+		return e.EmbedDocuments(ctx, content)
+	})
+}
+
+// MultiEmbedder spreads calls across embedders using (weighted) round-robin. When the selected
+// embedder fails the call is retried on the others, and failing embedders are skipped until
+// their cooldown passes. All embedders must produce vectors of the same dimension, checked like
+// for FallbackEmbedder
+type MultiEmbedder struct {
+	group *providerGroup
+}
+
+func NewMultiEmbedder(embedders []Embedder, opts ...GroupOption) *MultiEmbedder {
+	return &MultiEmbedder{group: newProviderGroup(embedders, opts)}
+}
+
+// order picks the next provider with smooth weighted round-robin and lists the rest after it
+func (m *MultiEmbedder) order() []int {
+	m.group.mu.Lock()
+	defer m.group.mu.Unlock()
+	if len(m.group.providers) == 0 {
+		return nil
+	}
+	total, best := 0, 0
+	for i, p := range m.group.providers {
+		p.current += p.weight
+		total += p.weight
+		if p.current > m.group.providers[best].current {
+			best = i
+		}
+	}
+	m.group.providers[best].current -= total
+
+	order := make([]int, 0, len(m.group.providers))
+	for i := range m.group.providers {
+		order = append(order, (best+i)%len(m.group.providers))
+	}
+	return order
+}
+
+func (m *MultiEmbedder) Model() string {
+	return m.group.model()
+}
+
+// Verify embeds a probe text with every embedder and errors if they disagree on the dimension
+func (m *MultiEmbedder) Verify(ctx context.Context) error {
+	return m.group.verify(ctx)
+}
+
+// Health returns the health of every embedder, in the order they were given
+func (m *MultiEmbedder) Health() []ProviderHealth {
+	return m.group.health()
+}
+
+func (m *MultiEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	vectors, err := m.group.embed(ctx, m.order(), func(e Embedder) ([][]float32, error) {
+		vector, err := e.EmbedQuery(ctx, content)
+		return [][]float32{vector}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (m *MultiEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	return m.group.embed(ctx, m.order(), func(e Embedder) ([][]float32, error) {
+		return e.EmbedDocuments(ctx, content)
+	})
+}
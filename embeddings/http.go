@@ -0,0 +1,67 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// postJSON sends payload to url and decodes a successful json response into out, logging the
+// request with logger. provider is used to give logs and errors some context and batchSize is
+// the number of texts sent
+func postJSON(ctx context.Context, client *http.Client, logger *slog.Logger, url, authHeader, provider string,
+	batchSize int, payload, out any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if authHeader != "" {
+		req.Header.Add("Authorization", authHeader)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("provider", provider),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Int("batchSize", batchSize),
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	attrs = append(attrs, slog.Duration("latency", time.Since(start)))
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelError, "embedding request failed", append(attrs, slog.Any("error", err))...)
+		return err
+	}
+	defer resp.Body.Close()
+
+	level := slog.LevelDebug
+	if resp.StatusCode != http.StatusOK {
+		level = slog.LevelWarn
+	}
+	logger.LogAttrs(ctx, level, "embedding request", append(attrs, slog.Int("status", resp.StatusCode))...)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading %s embeddings response body: %w", provider, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error getting %s embeddings. Status: %s Response: %s", provider, resp.Status, string(respBody))
+	}
+
+	err = json.Unmarshal(respBody, out)
+	if err != nil {
+		return fmt.Errorf("error unmarshaling %s embeddings response: %w\nresponse body: %s", provider, err, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Middleware decorates an Embedder with additional behaviour
+type Middleware func(Embedder) Embedder
+
+// Chain wraps e with the given middlewares. The first middleware is the outermost one, so
+// Chain(e, Normalize(), TruncateDimensions(256)) normalizes the truncated vectors
+func Chain(e Embedder, middlewares ...Middleware) Embedder {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		e = middlewares[i](e)
+	}
+	return e
+}
+
+// wrappedEmbedder is the base of all middlewares, it forwards the model of the wrapped embedder
+type wrappedEmbedder struct {
+	inner Embedder
+}
+
+func (w wrappedEmbedder) Model() string {
+	return modelOf(w.inner)
+}
+
+// Normalize L2 normalizes every vector returned by the wrapped embedder
+func Normalize() Middleware {
+	return func(e Embedder) Embedder {
+		return normalizingEmbedder{wrappedEmbedder{e}}
+	}
+}
+
+type normalizingEmbedder struct {
+	wrappedEmbedder
+}
+
+func (n normalizingEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	vector, err := n.inner.EmbedQuery(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	return l2Normalize(append([]float32(nil), vector...)), nil
+}
+
+func (n normalizingEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	vectors, err := n.inner.EmbedDocuments(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	// normalize copies, the wrapped embedder may hand out vectors it holds on to like a cache
+	for i, vector := range vectors {
+		vectors[i] = l2Normalize(append([]float32(nil), vector...))
+	}
+	return vectors, nil
+}
+
+// TruncateDimensions keeps only the first dimension values of every vector, as supported by
+// Matryoshka representation models. Truncated vectors are not unit length anymore, chain with
+// Normalize when the collection uses cosine or inner product distance
+func TruncateDimensions(dimension int) Middleware {
+	return func(e Embedder) Embedder {
+		return truncatingEmbedder{wrappedEmbedder{e}, dimension}
+	}
+}
+
+type truncatingEmbedder struct {
+	wrappedEmbedder
+	dimension int
+}
+
+func (t truncatingEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	vector, err := t.inner.EmbedQuery(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	return t.truncate(vector), nil
+}
+
+func (t truncatingEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	vectors, err := t.inner.EmbedDocuments(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	for i, vector := range vectors {
+		vectors[i] = t.truncate(vector)
+	}
+	return vectors, nil
+}
+
+func (t truncatingEmbedder) truncate(vector []float32) []float32 {
+	if len(vector) > t.dimension {
+		return vector[:t.dimension]
+	}
+	return vector
+}
+
+// Prefix prepends queryPrefix to queries and documentPrefix to documents before embedding them,
+// for models trained with instructions like E5's "query: " and "passage: "
+func Prefix(queryPrefix, documentPrefix string) Middleware {
+	return func(e Embedder) Embedder {
+		return prefixingEmbedder{wrappedEmbedder{e}, queryPrefix, documentPrefix}
+	}
+}
+
+type prefixingEmbedder struct {
+	wrappedEmbedder
+	queryPrefix    string
+	documentPrefix string
+}
+
+func (p prefixingEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	return p.inner.EmbedQuery(ctx, p.queryPrefix+content)
+}
+
+func (p prefixingEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	prefixed := make([]string, len(content))
+	for i, text := range content {
+		prefixed[i] = p.documentPrefix + text
+	}
+	return p.inner.EmbedDocuments(ctx, prefixed)
+}
+
+// Operations reported to an EmbedHook
+const (
+	OpEmbedQuery     = "EmbedQuery"
+	OpEmbedDocuments = "EmbedDocuments"
+)
+
+// EmbedCall describes a single call to an instrumented embedder
+type EmbedCall struct {
+	Operation string
+	Model     string
+	Texts     int
+	Duration  time.Duration
+	Err       error
+}
+
+// EmbedHook is called after every call to an instrumented embedder
+type EmbedHook func(ctx context.Context, call EmbedCall)
+
+// Instrument reports every call to the wrapped embedder to hook, for timing and counting calls
+func Instrument(hook EmbedHook) Middleware {
+	return func(e Embedder) Embedder {
+		return instrumentedEmbedder{wrappedEmbedder{e}, hook}
+	}
+}
+
+type instrumentedEmbedder struct {
+	wrappedEmbedder
+	hook EmbedHook
+}
+
+func (i instrumentedEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	start := time.Now()
+	vector, err := i.inner.EmbedQuery(ctx, content)
+	i.hook(ctx, EmbedCall{
+		Operation: OpEmbedQuery, Model: i.Model(), Texts: 1, Duration: time.Since(start), Err: err,
+	})
+	return vector, err
+}
+
+func (i instrumentedEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	start := time.Now()
+	vectors, err := i.inner.EmbedDocuments(ctx, content)
+	i.hook(ctx, EmbedCall{
+		Operation: OpEmbedDocuments, Model: i.Model(), Texts: len(content), Duration: time.Since(start), Err: err,
+	})
+	return vectors, err
+}
+
+// EmbedTracer is notified before and after every call to a traced embedder
+type EmbedTracer interface {
+	// EmbedStart is called before a call with Duration and Err unset. The returned context is
+	// passed to the wrapped embedder and to EmbedEnd, so tracers can carry spans in it
+	EmbedStart(ctx context.Context, call EmbedCall) context.Context
+	EmbedEnd(ctx context.Context, call EmbedCall)
+}
+
+// Trace notifies tracer around every call to the wrapped embedder
+func Trace(tracer EmbedTracer) Middleware {
+	return func(e Embedder) Embedder {
+		return tracedEmbedder{wrappedEmbedder{e}, tracer}
+	}
+}
+
+type tracedEmbedder struct {
+	wrappedEmbedder
+	tracer EmbedTracer
+}
+
+func (t tracedEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	call := EmbedCall{Operation: OpEmbedQuery, Model: t.Model(), Texts: 1}
+	ctx = t.tracer.EmbedStart(ctx, call)
+	start := time.Now()
+	vector, err := t.inner.EmbedQuery(ctx, content)
+	call.Duration, call.Err = time.Since(start), err
+	t.tracer.EmbedEnd(ctx, call)
+	return vector, err
+}
+
+func (t tracedEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	call := EmbedCall{Operation: OpEmbedDocuments, Model: t.Model(), Texts: len(content)}
+	ctx = t.tracer.EmbedStart(ctx, call)
+	start := time.Now()
+	vectors, err := t.inner.EmbedDocuments(ctx, content)
+	call.Duration, call.Err = time.Since(start), err
+	t.tracer.EmbedEnd(ctx, call)
+	return vectors, err
+}
+
+// DimensionError is returned when an embedder returns a vector of an unexpected length
+type DimensionError struct {
+	Expected int
+	Got      int
+}
+
+func (d DimensionError) Error() string {
+	return fmt.Sprintf("embedder returned a vector of dimension %d, expected %d", d.Got, d.Expected)
+}
+
+// AssertDimension fails calls with a DimensionError when the wrapped embedder returns vectors
+// that are not of the given dimension, or a different number of vectors than texts
+func AssertDimension(dimension int) Middleware {
+	return func(e Embedder) Embedder {
+		return dimensionAssertingEmbedder{wrappedEmbedder{e}, dimension}
+	}
+}
+
+type dimensionAssertingEmbedder struct {
+	wrappedEmbedder
+	dimension int
+}
+
+func (d dimensionAssertingEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	vector, err := d.inner.EmbedQuery(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	if len(vector) != d.dimension {
+		return nil, DimensionError{Expected: d.dimension, Got: len(vector)}
+	}
+	return vector, nil
+}
+
+func (d dimensionAssertingEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	vectors, err := d.inner.EmbedDocuments(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(content) {
+		return nil, fmt.Errorf("embedder returned %d embeddings for %d documents", len(vectors), len(content))
+	}
+	for _, vector := range vectors {
+		if len(vector) != d.dimension {
+			return nil, DimensionError{Expected: d.dimension, Got: len(vector)}
+		}
+	}
+	return vectors, nil
+}
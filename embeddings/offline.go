@@ -0,0 +1,147 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strings"
+	"unicode"
+)
+
+// HashEmbedder is a deterministic, offline embedder using feature hashing. Every lower-cased word
+// and character n-gram of the text is hashed into one of dimension buckets with a signed weight
+// and the result is L2 normalized, so texts sharing words and spellings end up close together.
+// It needs no model or network and is meant for tests, demos and development
+type HashEmbedder struct {
+	dimension int
+	nGram     int
+}
+
+// HashOption configures a HashEmbedder
+type HashOption func(*HashEmbedder)
+
+// WithHashNGram sets the size of the character n-grams hashed in addition to words.
+// Defaults to 3, 0 only hashes words
+func WithHashNGram(n int) HashOption {
+	return func(h *HashEmbedder) {
+		h.nGram = n
+	}
+}
+
+// NewHashEmbedder returns a HashEmbedder of vectors with dimension values. Negative dimensions are
+// treated as 0, embedding every text as an empty vector
+func NewHashEmbedder(dimension int, opts ...HashOption) HashEmbedder {
+	h := HashEmbedder{dimension: max(dimension, 0), nGram: 3}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	return h
+}
+
+func (h HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d-ngram%d", h.dimension, h.nGram)
+}
+
+func (h HashEmbedder) EmbedQuery(_ context.Context, content string) ([]float32, error) {
+	return h.embed(content), nil
+}
+
+func (h HashEmbedder) EmbedDocuments(_ context.Context, content []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(content))
+	for i, text := range content {
+		embeddings[i] = h.embed(text)
+	}
+	return embeddings, nil
+}
+
+func (h HashEmbedder) embed(content string) []float32 {
+	vector := make([]float32, h.dimension)
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		h.add(vector, "w:"+word)
+		if h.nGram <= 0 {
+			continue
+		}
+		// pad words so n-grams at the start and end of a word are distinct from inner ones
+		runes := []rune("<" + word + ">")
+		for i := 0; i+h.nGram <= len(runes); i++ {
+			h.add(vector, "g:"+string(runes[i:i+h.nGram]))
+		}
+	}
+	return l2Normalize(vector)
+}
+
+func (h HashEmbedder) add(vector []float32, feature string) {
+	if len(vector) == 0 {
+		return
+	}
+	hasher := fnv.New64a()
+	hasher.Write([]byte(feature))
+	sum := hasher.Sum64()
+	// the top bit decides the sign so collisions cancel out rather than accumulate
+	if sum>>63 == 1 {
+		vector[sum%uint64(len(vector))]--
+	} else {
+		vector[sum%uint64(len(vector))]++
+	}
+}
+
+// RandomEmbedder returns L2 normalized random vectors that are deterministic for a given seed and text.
+// Similarity between vectors carries no meaning, but identical texts always get identical vectors
+type RandomEmbedder struct {
+	dimension int
+	seed      int64
+}
+
+// NewRandomEmbedder returns a RandomEmbedder of vectors with dimension values. Negative dimensions
+// are treated as 0, embedding every text as an empty vector
+func NewRandomEmbedder(dimension int, seed int64) RandomEmbedder {
+	return RandomEmbedder{dimension: max(dimension, 0), seed: seed}
+}
+
+func (r RandomEmbedder) Model() string {
+	return fmt.Sprintf("random-%d-seed%d", r.dimension, r.seed)
+}
+
+func (r RandomEmbedder) EmbedQuery(_ context.Context, content string) ([]float32, error) {
+	return r.embed(content), nil
+}
+
+func (r RandomEmbedder) EmbedDocuments(_ context.Context, content []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(content))
+	for i, text := range content {
+		embeddings[i] = r.embed(text)
+	}
+	return embeddings, nil
+}
+
+func (r RandomEmbedder) embed(content string) []float32 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(content))
+	rnd := rand.New(rand.NewSource(r.seed ^ int64(hasher.Sum64())))
+	vector := make([]float32, r.dimension)
+	for i := range vector {
+		vector[i] = float32(rnd.NormFloat64())
+	}
+	return l2Normalize(vector)
+}
+
+// l2Normalize scales vector in place to unit length. Zero vectors are returned unchanged
+func l2Normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
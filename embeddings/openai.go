@@ -1,17 +1,18 @@
 package embeddings
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"github.com/rs/zerolog/log"
-	"io"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/urjitbhatia/gochroma/internal/logging"
 )
 
 var openAIURL = "https://api.openai.com/v1/"
 var openAIEmbeddingsPath = "/embeddings"
+var openAIModel = "text-embedding-ada-002"
 
 type embeddingResponse struct {
 	Data []struct {
@@ -30,21 +31,49 @@ type OpenAIClient struct {
 	client         *http.Client
 	authHeader     string
 	openAIEndpoint string
+	usageTracker   UsageTracker
+	logger         *slog.Logger
+}
+
+// OpenAIOption configures an OpenAIClient
+type OpenAIOption func(*OpenAIClient)
+
+// WithOpenAIUsageTracker reports the tokens billed for every request to tracker
+func WithOpenAIUsageTracker(tracker UsageTracker) OpenAIOption {
+	return func(o *OpenAIClient) {
+		o.usageTracker = tracker
+	}
+}
+
+// WithOpenAILogger logs every request and its token usage with handler
+func WithOpenAILogger(handler slog.Handler) OpenAIOption {
+	return func(o *OpenAIClient) {
+		o.logger = slog.New(handler)
+	}
 }
 
-func NewOpenAIClient(key string) OpenAIClient {
-	return NewOpenAIClientWithHTTP(openAIURL, key, http.DefaultClient)
+func NewOpenAIClient(key string, opts ...OpenAIOption) OpenAIClient {
+	return NewOpenAIClientWithHTTP(openAIURL, key, http.DefaultClient, opts...)
 }
 
-func NewOpenAIClientWithHTTP(openAIEndpoint, key string, client *http.Client) OpenAIClient {
+func NewOpenAIClientWithHTTP(openAIEndpoint, key string, client *http.Client, opts ...OpenAIOption) OpenAIClient {
 	if openAIEndpoint == "" {
 		openAIEndpoint = openAIURL
 	}
-	return OpenAIClient{
+	o := OpenAIClient{
 		client:         client,
 		authHeader:     fmt.Sprintf("Bearer %s", key),
 		openAIEndpoint: openAIEndpoint,
+		logger:         logging.Discard,
+	}
+	for _, opt := range opts {
+		opt(&o)
 	}
+	return o
+}
+
+func (o *OpenAIClient) Model() string {
+	return openAIModel
 }
 
 func (o *OpenAIClient) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
@@ -55,54 +84,26 @@ func (o *OpenAIClient) EmbedQuery(ctx context.Context, content string) ([]float3
 	return embeddings[0], nil
 }
 
-func (o *OpenAIClient) EmbedDocuments(_ context.Context, content []string) ([][]float32, error) {
-	body, err := json.Marshal(map[string]any{
-		"model": "text-embedding-ada-002",
+func (o *OpenAIClient) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	payload := map[string]any{
+		"model": openAIModel,
 		"input": content,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, o.openAIEndpoint+openAIEmbeddingsPath, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", o.authHeader)
-
-	resp, err := o.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("error getting openai embeddings and unable to read response body. status: %s", resp.Status)
-		}
-		return nil, fmt.Errorf("error getting openai embeddings. Status: %s Response: %s", resp.Status, string(body))
 	}
 
 	er := embeddingResponse{}
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading openai embeddings response body: %w", err)
-	}
-
-	err = json.Unmarshal(respBody, &er)
+	start := time.Now()
+	err := postJSON(ctx, o.client, o.logger, o.openAIEndpoint+openAIEmbeddingsPath, o.authHeader, "openai",
+		len(content), payload, &er)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling openai embeddings response: %w\nresponse body: %s", err, string(respBody))
+		return nil, err
 	}
 
-	log.Debug().
-		Str("endpoint", o.openAIEndpoint).
-		Str("embeddingModelUsed", er.Model).
-		Int("promptTokensUsed", er.Usage.PromptTokens).
-		Int("totalTokensUsed", er.Usage.TotalTokens).
-		Msg("openai embedding token usage")
+	o.logger.LogAttrs(ctx, slog.LevelDebug, "openai embedding token usage",
+		slog.String("endpoint", o.openAIEndpoint),
+		slog.String("embeddingModelUsed", er.Model),
+		slog.Int("promptTokensUsed", er.Usage.PromptTokens),
+		slog.Int("totalTokensUsed", er.Usage.TotalTokens))
+	trackUsage(ctx, o.usageTracker, o.Model(), er.Usage.TotalTokens, start)
 
 	if len(er.Data) == 0 {
 		return nil, fmt.Errorf("something went wrong, got no embeddings from openai")
@@ -0,0 +1,83 @@
+package embeddings
+
+import (
+	"context"
+
+	"github.com/urjitbhatia/gochroma/internal/ratelimit"
+)
+
+// Limits caps the calls made to an embedder wrapped with RateLimit. Zero values are unlimited
+type Limits struct {
+	RequestsPerMinute int
+	// TokensPerMinute caps the tokens sent, as estimated by EstimateTokens before every call
+	TokensPerMinute int
+	MaxInFlight     int
+}
+
+// EstimateTokens estimates the tokens of texts at four characters per token, close enough to
+// OpenAI's and Cohere's tokenizers for english text to stay within quotas
+func EstimateTokens(texts ...string) int {
+	tokens := 0
+	for _, text := range texts {
+		tokens += (len(text) + 3) / 4
+	}
+	return tokens
+}
+
+// RateLimit blocks calls to the wrapped embedder until they fit within limits, or fails them with
+// the context's error when it is done first. Wrap the provider client itself, so calls served
+// from a cache are not limited
+func RateLimit(limits Limits) Middleware {
+	requests := ratelimit.Limiter{}
+	if limits.RequestsPerMinute > 0 {
+		requests.Bucket = ratelimit.NewBucket(float64(limits.RequestsPerMinute)/60, limits.RequestsPerMinute)
+	}
+	if limits.MaxInFlight > 0 {
+		requests.Semaphore = ratelimit.NewSemaphore(limits.MaxInFlight)
+	}
+	var tokens *ratelimit.Bucket
+	if limits.TokensPerMinute > 0 {
+		tokens = ratelimit.NewBucket(float64(limits.TokensPerMinute)/60, limits.TokensPerMinute)
+	}
+	return func(e Embedder) Embedder {
+		return rateLimitedEmbedder{wrappedEmbedder{e}, requests, tokens}
+	}
+}
+
+type rateLimitedEmbedder struct {
+	wrappedEmbedder
+	requests ratelimit.Limiter
+	tokens   *ratelimit.Bucket
+}
+
+func (r rateLimitedEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	release, err := r.acquire(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.inner.EmbedQuery(ctx, content)
+}
+
+func (r rateLimitedEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	release, err := r.acquire(ctx, content...)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.inner.EmbedDocuments(ctx, content)
+}
+
+func (r rateLimitedEmbedder) acquire(ctx context.Context, texts ...string) (func(), error) {
+	release, err := r.requests.Acquire(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	if r.tokens != nil {
+		if err := r.tokens.Wait(ctx, float64(EstimateTokens(texts...))); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
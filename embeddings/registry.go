@@ -0,0 +1,47 @@
+package embeddings
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory creates an embedder for the given model
+type Factory func(model string) (Embedder, error)
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+}{factories: map[string]Factory{}}
+
+// Register makes an embedder available by name, so collections recording that name in their
+// metadata can be bound to an embedder for the same model when they are fetched again.
+// Registering a name twice replaces the previous factory
+func Register(name string, factory Factory) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.factories[name] = factory
+}
+
+// New creates an embedder for model using the factory registered under name
+func New(name, model string) (Embedder, error) {
+	registry.RLock()
+	factory, ok := registry.factories[name]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no embedder registered with name %q", name)
+	}
+	return factory(model)
+}
+
+// Registered returns the sorted names of all registered embedders
+func Registered() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
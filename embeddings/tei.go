@@ -0,0 +1,161 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/urjitbhatia/gochroma/internal/logging"
+)
+
+var teiEmbedPath = "/embed"
+var hfInferenceURL = "https://api-inference.huggingface.co/pipeline/feature-extraction/"
+
+type teiEmbedRequest struct {
+	Inputs     []string `json:"inputs"`
+	Normalize  bool     `json:"normalize"`
+	Truncate   bool     `json:"truncate"`
+	PromptName *string  `json:"prompt_name,omitempty"`
+}
+
+// TEIClient generates embeddings using a Hugging Face Text Embeddings Inference server
+// or the Hugging Face Inference API feature-extraction pipeline, which accepts the same payload
+type TEIClient struct {
+	client       *http.Client
+	authHeader   string
+	endpoint     string
+	model        string
+	normalize    bool
+	truncate     bool
+	promptName   string
+	usageTracker UsageTracker
+	logger       *slog.Logger
+}
+
+// TEIOption configures a TEIClient
+type TEIOption func(*TEIClient)
+
+// WithTEINormalize sets whether the server L2 normalizes the returned vectors. Defaults to true
+func WithTEINormalize(normalize bool) TEIOption {
+	return func(t *TEIClient) {
+		t.normalize = normalize
+	}
+}
+
+// WithTEITruncate sets whether inputs longer than the model's max length are truncated
+// instead of rejected by the server. Defaults to false
+func WithTEITruncate(truncate bool) TEIOption {
+	return func(t *TEIClient) {
+		t.truncate = truncate
+	}
+}
+
+// WithTEIPromptName selects one of the prompts configured for the model (for example "query")
+// which the server prepends to every input
+func WithTEIPromptName(name string) TEIOption {
+	return func(t *TEIClient) {
+		t.promptName = name
+	}
+}
+
+// WithTEIModel records the model served by a TEI server. It is only used to identify the embedder,
+// for example in cache keys, and defaults to the server endpoint
+func WithTEIModel(model string) TEIOption {
+	return func(t *TEIClient) {
+		t.model = model
+	}
+}
+
+// WithTEIAPIKey sends the key as a bearer token. Required for the Hugging Face Inference API
+// and for TEI servers started with --api-key
+func WithTEIAPIKey(key string) TEIOption {
+	return func(t *TEIClient) {
+		t.authHeader = fmt.Sprintf("Bearer %s", key)
+	}
+}
+
+// WithTEIUsageTracker reports every request to tracker. TEI doesn't report token counts,
+// so only requests and latency are tracked
+func WithTEIUsageTracker(tracker UsageTracker) TEIOption {
+	return func(t *TEIClient) {
+		t.usageTracker = tracker
+	}
+}
+
+// WithTEILogger logs every request with handler
+func WithTEILogger(handler slog.Handler) TEIOption {
+	return func(t *TEIClient) {
+		t.logger = slog.New(handler)
+	}
+}
+
+// WithTEIHTTPClient overrides the http client used to talk to the server
+func WithTEIHTTPClient(client *http.Client) TEIOption {
+	return func(t *TEIClient) {
+		t.client = client
+	}
+}
+
+// NewTEIClient returns a client for a self-hosted TEI server, for example http://localhost:8080
+func NewTEIClient(teiEndpoint string, opts ...TEIOption) TEIClient {
+	return newTEIClient(strings.TrimSuffix(teiEndpoint, "/")+teiEmbedPath, opts)
+}
+
+// NewHFInferenceClient returns a client for the Hugging Face Inference API feature-extraction
+// pipeline of the given model, for example "BAAI/bge-small-en-v1.5"
+func NewHFInferenceClient(model, key string, opts ...TEIOption) TEIClient {
+	opts = append([]TEIOption{WithTEIAPIKey(key), WithTEIModel(model)}, opts...)
+	return newTEIClient(hfInferenceURL+model, opts)
+}
+
+func newTEIClient(endpoint string, opts []TEIOption) TEIClient {
+	t := TEIClient{
+		client:    http.DefaultClient,
+		logger:    logging.Discard,
+		endpoint:  endpoint,
+		model:     endpoint,
+		normalize: true,
+	}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t
+}
+
+func (t *TEIClient) Model() string {
+	return t.model
+}
+
+func (t *TEIClient) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	embeddings, err := t.EmbedDocuments(ctx, []string{content})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (t *TEIClient) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	payload := teiEmbedRequest{
+		Inputs:    content,
+		Normalize: t.normalize,
+		Truncate:  t.truncate,
+	}
+	if t.promptName != "" {
+		payload.PromptName = &t.promptName
+	}
+	var embeddings [][]float32
+	start := time.Now()
+	err := postJSON(ctx, t.client, t.logger, t.endpoint, t.authHeader, "tei", len(content), payload, &embeddings)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(content) {
+		return nil, fmt.Errorf("something went wrong, requested %d embeddings from tei but got %d",
+			len(content), len(embeddings))
+	}
+	trackUsage(ctx, t.usageTracker, t.model, 0, start)
+	return embeddings, nil
+}
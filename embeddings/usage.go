@@ -0,0 +1,92 @@
+package embeddings
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Usage is reported to a UsageTracker after every successful embedding request
+type Usage struct {
+	Model    string
+	Tokens   int // as billed by the provider, 0 for providers that don't report usage
+	Requests int
+	Latency  time.Duration
+}
+
+// UsageTracker receives the usage of embedding providers
+type UsageTracker interface {
+	TrackUsage(ctx context.Context, usage Usage)
+}
+
+func trackUsage(ctx context.Context, tracker UsageTracker, model string, tokens int, start time.Time) {
+	if tracker == nil {
+		return
+	}
+	tracker.TrackUsage(ctx, Usage{Model: model, Tokens: tokens, Requests: 1, Latency: time.Since(start)})
+}
+
+// ModelUsage is the accumulated usage of a single model
+type ModelUsage struct {
+	Tokens        int
+	Requests      int
+	Latency       time.Duration // total latency of all requests
+	EstimatedCost float64
+}
+
+// UsageAggregator is an in-memory UsageTracker keeping totals per model
+type UsageAggregator struct {
+	mu     sync.Mutex
+	prices map[string]float64
+	totals map[string]ModelUsage
+}
+
+// NewUsageAggregator returns an aggregator estimating cost with the given prices per million
+// tokens by model name. Models without a price are tracked with a zero cost
+func NewUsageAggregator(pricesPerMillionTokens map[string]float64) *UsageAggregator {
+	prices := map[string]float64{}
+	for model, price := range pricesPerMillionTokens {
+		prices[model] = price
+	}
+	return &UsageAggregator{prices: prices, totals: map[string]ModelUsage{}}
+}
+
+func (u *UsageAggregator) TrackUsage(_ context.Context, usage Usage) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	total := u.totals[usage.Model]
+	total.Tokens += usage.Tokens
+	total.Requests += usage.Requests
+	total.Latency += usage.Latency
+	total.EstimatedCost += float64(usage.Tokens) * u.prices[usage.Model] / 1e6
+	u.totals[usage.Model] = total
+}
+
+// Totals returns a copy of the usage per model
+func (u *UsageAggregator) Totals() map[string]ModelUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	totals := make(map[string]ModelUsage, len(u.totals))
+	for model, total := range u.totals {
+		totals[model] = total
+	}
+	return totals
+}
+
+// EstimatedCost returns the estimated cost over all models
+func (u *UsageAggregator) EstimatedCost() float64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var cost float64
+	for _, total := range u.totals {
+		cost += total.EstimatedCost
+	}
+	return cost
+}
+
+// Reset clears all totals, for example after billing a period
+func (u *UsageAggregator) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.totals = map[string]ModelUsage{}
+}
@@ -0,0 +1,140 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/urjitbhatia/gochroma/internal/logging"
+)
+
+var voyageURL = "https://api.voyageai.com/v1"
+var voyageEmbeddingsPath = "/embeddings"
+
+// Voyage input types, sent depending on whether documents or a query are being embedded
+const (
+	VoyageInputDocument = "document"
+	VoyageInputQuery    = "query"
+)
+
+// VoyageClient generates embeddings using the Voyage AI embeddings API. Documents are embedded with
+// input_type=document and queries with input_type=query
+type VoyageClient struct {
+	client         *http.Client
+	authHeader     string
+	voyageEndpoint string
+	model          string
+	truncation     *bool
+	usageTracker   UsageTracker
+	logger         *slog.Logger
+}
+
+// VoyageOption configures a VoyageClient
+type VoyageOption func(*VoyageClient)
+
+// WithVoyageModel sets the embedding model. Defaults to voyage-2
+func WithVoyageModel(model string) VoyageOption {
+	return func(v *VoyageClient) {
+		v.model = model
+	}
+}
+
+// WithVoyageTruncation sets whether inputs longer than the model's context length are truncated
+// instead of rejected. Defaults to the server side default (true)
+func WithVoyageTruncation(truncation bool) VoyageOption {
+	return func(v *VoyageClient) {
+		v.truncation = &truncation
+	}
+}
+
+// WithVoyageUsageTracker reports the tokens billed for every request to tracker
+func WithVoyageUsageTracker(tracker UsageTracker) VoyageOption {
+	return func(v *VoyageClient) {
+		v.usageTracker = tracker
+	}
+}
+
+// WithVoyageEndpoint overrides the Voyage api base url
+func WithVoyageEndpoint(endpoint string) VoyageOption {
+	return func(v *VoyageClient) {
+		v.voyageEndpoint = endpoint
+	}
+}
+
+// WithVoyageLogger logs every request with handler
+func WithVoyageLogger(handler slog.Handler) VoyageOption {
+	return func(v *VoyageClient) {
+		v.logger = slog.New(handler)
+	}
+}
+
+// WithVoyageHTTPClient overrides the http client used to talk to Voyage
+func WithVoyageHTTPClient(client *http.Client) VoyageOption {
+	return func(v *VoyageClient) {
+		v.client = client
+	}
+}
+
+func NewVoyageClient(key string, opts ...VoyageOption) VoyageClient {
+	v := VoyageClient{
+		client:         http.DefaultClient,
+		logger:         logging.Discard,
+		authHeader:     fmt.Sprintf("Bearer %s", key),
+		voyageEndpoint: voyageURL,
+		model:          "voyage-2",
+	}
+	for _, opt := range opts {
+		opt(&v)
+	}
+	return v
+}
+
+func (v *VoyageClient) Model() string {
+	return v.model
+}
+
+func (v *VoyageClient) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	embeddings, err := v.embed(ctx, []string{content}, VoyageInputQuery)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (v *VoyageClient) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	return v.embed(ctx, content, VoyageInputDocument)
+}
+
+func (v *VoyageClient) embed(ctx context.Context, content []string, inputType string) ([][]float32, error) {
+	payload := map[string]any{
+		"model":      v.model,
+		"input":      content,
+		"input_type": inputType,
+	}
+	if v.truncation != nil {
+		payload["truncation"] = *v.truncation
+	}
+
+	er := embeddingResponse{}
+	start := time.Now()
+	err := postJSON(ctx, v.client, v.logger, v.voyageEndpoint+voyageEmbeddingsPath, v.authHeader, "voyage",
+		len(content), payload, &er)
+	if err != nil {
+		return nil, err
+	}
+	if len(er.Data) != len(content) {
+		return nil, fmt.Errorf("something went wrong, requested %d embeddings from voyage but got %d",
+			len(content), len(er.Data))
+	}
+	trackUsage(ctx, v.usageTracker, v.model, er.Usage.TotalTokens, start)
+	// data is documented to be in input order but carries the index anyway, so be defensive
+	sort.SliceStable(er.Data, func(i, j int) bool { return er.Data[i].Index < er.Data[j].Index })
+	embeddings := make([][]float32, len(er.Data))
+	for i, data := range er.Data {
+		embeddings[i] = data.Embedding
+	}
+	return embeddings, nil
+}
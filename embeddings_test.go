@@ -0,0 +1,562 @@
+package chroma_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/urjitbhatia/gochroma/embeddings"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// recordingEmbedder wraps testEmbedder and records every batch it is asked to embed
+type recordingEmbedder struct {
+	testEmbedder
+	batches [][]string
+}
+
+func (e *recordingEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	e.batches = append(e.batches, content)
+	return e.testEmbedder.EmbedDocuments(ctx, content)
+}
+
+func (e *recordingEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	e.batches = append(e.batches, []string{content})
+	return e.testEmbedder.EmbedQuery(ctx, content)
+}
+
+// failingEmbedder fails every call while fail is set
+type failingEmbedder struct {
+	testEmbedder
+	fail  bool
+	calls int
+}
+
+func (e *failingEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	e.calls++
+	if e.fail {
+		return nil, errors.New("provider down")
+	}
+	return e.testEmbedder.EmbedDocuments(ctx, content)
+}
+
+func (e *failingEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	e.calls++
+	if e.fail {
+		return nil, errors.New("provider down")
+	}
+	return e.testEmbedder.EmbedQuery(ctx, content)
+}
+
+// flakyEmbedder fails every call of the wrapped embedder while fail is set
+type flakyEmbedder struct {
+	embeddings.Embedder
+	fail bool
+}
+
+func (e *flakyEmbedder) EmbedDocuments(ctx context.Context, content []string) ([][]float32, error) {
+	if e.fail {
+		return nil, errors.New("provider down")
+	}
+	return e.Embedder.EmbedDocuments(ctx, content)
+}
+
+func (e *flakyEmbedder) EmbedQuery(ctx context.Context, content string) ([]float32, error) {
+	if e.fail {
+		return nil, errors.New("provider down")
+	}
+	return e.Embedder.EmbedQuery(ctx, content)
+}
+
+var _ = Describe("Embedders", func() {
+	Describe("TEI", func() {
+		It("embeds documents with the configured options", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				defer GinkgoRecover()
+				Expect(req.URL.String()).To(Equal("/embed"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer secret"))
+
+				payload := map[string]any{}
+				Expect(json.NewDecoder(req.Body).Decode(&payload)).To(Succeed())
+				Expect(payload["inputs"]).To(Equal([]any{"foo", "bar"}))
+				Expect(payload["normalize"]).To(BeFalse())
+				Expect(payload["truncate"]).To(BeTrue())
+				Expect(payload["prompt_name"]).To(Equal("query"))
+				rw.Write([]byte(`[[0.1, 0.2, 0.3], [1.1, 1.2, 1.3]]`))
+			}))
+			defer server.Close()
+
+			tei := embeddings.NewTEIClient(server.URL+"/",
+				embeddings.WithTEIAPIKey("secret"),
+				embeddings.WithTEINormalize(false),
+				embeddings.WithTEITruncate(true),
+				embeddings.WithTEIPromptName("query"),
+				embeddings.WithTEIHTTPClient(server.Client()))
+			ee, err := tei.EmbedDocuments(context.Background(), []string{"foo", "bar"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ee).To(Equal([][]float32{{0.1, 0.2, 0.3}, {1.1, 1.2, 1.3}}))
+		})
+
+		It("omits optional fields and reports server errors", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				defer GinkgoRecover()
+				Expect(req.Header.Get("Authorization")).To(BeEmpty())
+				payload := map[string]any{}
+				Expect(json.NewDecoder(req.Body).Decode(&payload)).To(Succeed())
+				Expect(payload).ToNot(HaveKey("prompt_name"))
+				Expect(payload["normalize"]).To(BeTrue())
+				rw.WriteHeader(http.StatusRequestEntityTooLarge)
+				rw.Write([]byte(`{"error":"Input validation error","error_type":"Validation"}`))
+			}))
+			defer server.Close()
+
+			tei := embeddings.NewTEIClient(server.URL)
+			_, err := tei.EmbedQuery(context.Background(), "foo")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Input validation error"))
+		})
+	})
+
+	Describe("input types", func() {
+		var payloads []map[string]any
+		var server *httptest.Server
+		BeforeEach(func() {
+			payloads = nil
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("sends cohere search_document and search_query", func() {
+			server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				defer GinkgoRecover()
+				Expect(req.URL.String()).To(Equal("/embed"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer key"))
+				payload := map[string]any{}
+				Expect(json.NewDecoder(req.Body).Decode(&payload)).To(Succeed())
+				payloads = append(payloads, payload)
+				texts := payload["texts"].([]any)
+				vectors := make([][]float32, len(texts))
+				for i := range texts {
+					vectors[i] = []float32{float32(i), 1}
+				}
+				json.NewEncoder(rw).Encode(map[string]any{"id": "x", "embeddings": vectors})
+			}))
+
+			cohere := embeddings.NewCohereClient("key",
+				embeddings.WithCohereEndpoint(server.URL),
+				embeddings.WithCohereHTTPClient(server.Client()),
+				embeddings.WithCohereModel("embed-multilingual-v3.0"),
+				embeddings.WithCohereTruncate("START"))
+			ee, err := cohere.EmbedDocuments(context.Background(), []string{"foo", "bar"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ee).To(Equal([][]float32{{0, 1}, {1, 1}}))
+			_, err = cohere.EmbedQuery(context.Background(), "foo")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(payloads).To(HaveLen(2))
+			Expect(payloads[0]["input_type"]).To(Equal(embeddings.CohereInputSearchDocument))
+			Expect(payloads[0]["model"]).To(Equal("embed-multilingual-v3.0"))
+			Expect(payloads[0]["truncate"]).To(Equal("START"))
+			Expect(payloads[1]["input_type"]).To(Equal(embeddings.CohereInputSearchQuery))
+		})
+
+		It("sends voyage document and query and keeps input order", func() {
+			server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				defer GinkgoRecover()
+				Expect(req.URL.String()).To(Equal("/embeddings"))
+				payload := map[string]any{}
+				Expect(json.NewDecoder(req.Body).Decode(&payload)).To(Succeed())
+				payloads = append(payloads, payload)
+				if len(payload["input"].([]any)) == 1 {
+					rw.Write([]byte(`{"data": [{"embedding": [5, 5], "index": 0}], "usage": {"total_tokens": 1}}`))
+					return
+				}
+				rw.Write([]byte(`{"data": [{"embedding": [2, 2], "index": 1}, {"embedding": [1, 1], "index": 0}]}`))
+			}))
+
+			voyage := embeddings.NewVoyageClient("key",
+				embeddings.WithVoyageEndpoint(server.URL),
+				embeddings.WithVoyageHTTPClient(server.Client()),
+				embeddings.WithVoyageTruncation(false))
+			ee, err := voyage.EmbedDocuments(context.Background(), []string{"foo", "bar"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ee).To(Equal([][]float32{{1, 1}, {2, 2}}))
+			e, err := voyage.EmbedQuery(context.Background(), "foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e).To(Equal([]float32{5, 5}))
+
+			Expect(payloads[0]["input_type"]).To(Equal(embeddings.VoyageInputDocument))
+			Expect(payloads[0]["model"]).To(Equal("voyage-2"))
+			Expect(payloads[0]["truncation"]).To(BeFalse())
+			Expect(payloads[1]["input_type"]).To(Equal(embeddings.VoyageInputQuery))
+		})
+	})
+
+	Describe("caching", func() {
+		It("only embeds cache misses in a single batch and keeps order", func() {
+			inner := &recordingEmbedder{}
+			cached := embeddings.NewCachingEmbedder(inner, embeddings.NewLRUCacheStore(100))
+
+			ee, err := cached.EmbedDocuments(context.Background(), []string{"a", "bb"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ee).To(Equal([][]float32{{1, 1.1, 2.2}, {2, 1.1, 2.2}}))
+
+			ee, err = cached.EmbedDocuments(context.Background(), []string{"ccc", "a", "dddd", "ccc", "bb"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ee).To(Equal([][]float32{
+				{3, 1.1, 2.2}, {1, 1.1, 2.2}, {4, 1.1, 2.2}, {3, 1.1, 2.2}, {2, 1.1, 2.2}}))
+			Expect(inner.batches).To(Equal([][]string{{"a", "bb"}, {"ccc", "dddd"}}))
+
+			// queries are cached separately from documents
+			_, err = cached.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = cached.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inner.batches).To(HaveLen(3))
+		})
+
+		It("keys the cache by model", func() {
+			store := embeddings.NewLRUCacheStore(100)
+			inner := &recordingEmbedder{}
+			_, err := embeddings.NewCachingEmbedder(inner, store, embeddings.WithCacheModel("m1")).
+				EmbedDocuments(context.Background(), []string{"a"})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = embeddings.NewCachingEmbedder(inner, store, embeddings.WithCacheModel("m2")).
+				EmbedDocuments(context.Background(), []string{"a"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inner.batches).To(HaveLen(2))
+			Expect(store.Len()).To(Equal(2))
+		})
+
+		It("evicts least recently used vectors", func() {
+			store := embeddings.NewLRUCacheStore(2)
+			Expect(store.Set("a", []float32{1})).To(Succeed())
+			Expect(store.Set("b", []float32{2})).To(Succeed())
+			_, ok, _ := store.Get("a")
+			Expect(ok).To(BeTrue())
+			Expect(store.Set("c", []float32{3})).To(Succeed())
+
+			_, ok, _ = store.Get("b")
+			Expect(ok).To(BeFalse())
+			v, ok, _ := store.Get("a")
+			Expect(ok).To(BeTrue())
+			Expect(v).To(Equal([]float32{1}))
+			Expect(store.Len()).To(Equal(2))
+		})
+
+		It("keeps vectors changed by callers out of the cache", func() {
+			store := embeddings.NewLRUCacheStore(2)
+			vector := []float32{1, 2}
+			Expect(store.Set("a", vector)).To(Succeed())
+			vector[0] = 9
+			cached, _, _ := store.Get("a")
+			cached[1] = 9
+			cached, _, _ = store.Get("a")
+			Expect(cached).To(Equal([]float32{1, 2}))
+		})
+
+		It("persists vectors on disk", func() {
+			dir := GinkgoT().TempDir()
+			store, err := embeddings.NewDiskCacheStore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			_, ok, err := store.Get("abcdef")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(store.Set("abcdef", []float32{0.5, -1.25, 3})).To(Succeed())
+
+			reopened, err := embeddings.NewDiskCacheStore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			v, ok, err := reopened.Get("abcdef")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(v).To(Equal([]float32{0.5, -1.25, 3}))
+		})
+	})
+
+	Describe("offline", func() {
+		cosine := func(a, b []float32) float64 {
+			var dot float64
+			for i := range a {
+				dot += float64(a[i]) * float64(b[i])
+			}
+			return dot
+		}
+		norm := func(v []float32) float64 {
+			return math.Sqrt(cosine(v, v))
+		}
+
+		It("hash embeds similar texts closer together", func() {
+			h := embeddings.NewHashEmbedder(256)
+			ee, err := h.EmbedDocuments(context.Background(), []string{
+				"the quick brown fox jumps",
+				"The quick brown fox jumped!",
+				"stock prices fell sharply today",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ee).To(HaveLen(3))
+			for _, e := range ee {
+				Expect(e).To(HaveLen(256))
+				Expect(norm(e)).To(BeNumerically("~", 1, 1e-5))
+			}
+			Expect(cosine(ee[0], ee[1])).To(BeNumerically(">", cosine(ee[0], ee[2])))
+
+			q, err := h.EmbedQuery(context.Background(), "the quick brown fox jumps")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(q).To(Equal(ee[0]))
+		})
+
+		It("random embeds deterministically per seed and text", func() {
+			r := embeddings.NewRandomEmbedder(16, 42)
+			a, err := r.EmbedQuery(context.Background(), "foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(a).To(HaveLen(16))
+			Expect(norm(a)).To(BeNumerically("~", 1, 1e-5))
+
+			again, _ := embeddings.NewRandomEmbedder(16, 42).EmbedDocuments(context.Background(), []string{"foo", "bar"})
+			Expect(again[0]).To(Equal(a))
+			Expect(again[1]).ToNot(Equal(a))
+
+			otherSeed, _ := embeddings.NewRandomEmbedder(16, 7).EmbedQuery(context.Background(), "foo")
+			Expect(otherSeed).ToNot(Equal(a))
+		})
+
+		It("treats negative dimensions as 0", func() {
+			h, err := embeddings.NewHashEmbedder(-1).EmbedQuery(context.Background(), "foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h).To(BeEmpty())
+			r, err := embeddings.NewRandomEmbedder(-1, 42).EmbedQuery(context.Background(), "foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r).To(BeEmpty())
+		})
+	})
+
+	Describe("middleware", func() {
+		It("chains prefixing, truncation and normalization", func() {
+			inner := &recordingEmbedder{}
+			e := embeddings.Chain(inner,
+				embeddings.Normalize(),
+				embeddings.TruncateDimensions(2),
+				embeddings.Prefix("query: ", "passage: "))
+
+			ee, err := e.EmbedDocuments(context.Background(), []string{"a"})
+			Expect(err).ToNot(HaveOccurred())
+			// "passage: a" has length 10, truncated to {10, 1.1} and then normalized
+			Expect(ee[0]).To(HaveLen(2))
+			Expect(ee[0][0]).To(BeNumerically("~", 10/math.Sqrt(100+1.21), 1e-6))
+			Expect(ee[0][1]).To(BeNumerically("~", 1.1/math.Sqrt(100+1.21), 1e-6))
+
+			q, err := e.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(q).To(HaveLen(2))
+			Expect(inner.batches).To(Equal([][]string{{"passage: a"}, {"query: a"}}))
+		})
+
+		It("reports calls to instrumentation hooks", func() {
+			var calls []embeddings.EmbedCall
+			h := embeddings.NewHashEmbedder(8)
+			e := embeddings.Instrument(func(_ context.Context, call embeddings.EmbedCall) {
+				calls = append(calls, call)
+			})(h)
+
+			_, err := e.EmbedDocuments(context.Background(), []string{"a", "b", "c"})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = e.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(calls).To(HaveLen(2))
+			Expect(calls[0].Operation).To(Equal(embeddings.OpEmbedDocuments))
+			Expect(calls[0].Texts).To(Equal(3))
+			Expect(calls[0].Model).To(Equal(h.Model()))
+			Expect(calls[1].Operation).To(Equal(embeddings.OpEmbedQuery))
+			Expect(calls[1].Texts).To(Equal(1))
+		})
+
+		It("asserts the dimension of returned vectors", func() {
+			e := embeddings.AssertDimension(4)(testEmbedder{})
+			_, err := e.EmbedQuery(context.Background(), "a")
+			Expect(err).To(MatchError(embeddings.DimensionError{Expected: 4, Got: 3}))
+			_, err = e.EmbedDocuments(context.Background(), []string{"a"})
+			Expect(err).To(MatchError(embeddings.DimensionError{Expected: 4, Got: 3}))
+
+			e = embeddings.AssertDimension(3)(testEmbedder{})
+			_, err = e.EmbedDocuments(context.Background(), []string{"a"})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rate limits requests and estimated tokens", func() {
+			inner := &recordingEmbedder{}
+			e := embeddings.RateLimit(embeddings.Limits{TokensPerMinute: 60, MaxInFlight: 1})(inner)
+			Expect(embeddings.EstimateTokens("abcd", "abcde")).To(Equal(3))
+
+			// 60 tokens empty the bucket, which then refills at one token per second
+			_, err := e.EmbedDocuments(context.Background(), []string{string(make([]byte, 240))})
+			Expect(err).ToNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err = e.EmbedQuery(ctx, "abcdefgh")
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(inner.batches).To(HaveLen(1))
+
+			e = embeddings.RateLimit(embeddings.Limits{RequestsPerMinute: 1})(inner)
+			_, err = e.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+			ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err = e.EmbedQuery(ctx, "a")
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(inner.batches).To(HaveLen(2))
+		})
+	})
+
+	Describe("provider groups", func() {
+		It("falls back to secondaries and skips unhealthy providers", func() {
+			primary := &failingEmbedder{fail: true}
+			secondary := &failingEmbedder{}
+			f := embeddings.NewFallbackEmbedderWithOptions([]embeddings.Embedder{primary, secondary},
+				embeddings.WithCooldown(time.Hour))
+
+			e, err := f.EmbedQuery(context.Background(), "abc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e).To(Equal([]float32{3, 1.1, 2.2}))
+			Expect(f.Health()[0].Healthy).To(BeFalse())
+			Expect(f.Health()[0].LastError).To(MatchError("provider down"))
+			Expect(f.Health()[1].Healthy).To(BeTrue())
+
+			// primary is in cooldown and not tried anymore
+			_, err = f.EmbedDocuments(context.Background(), []string{"a", "b"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(primary.calls).To(Equal(1))
+			Expect(secondary.calls).To(Equal(2))
+
+			// when everything is down, unhealthy providers are tried as a last resort
+			secondary.fail = true
+			_, err = f.EmbedQuery(context.Background(), "a")
+			Expect(err).To(MatchError(ContainSubstring("all embedders failed")))
+			Expect(primary.calls).To(Equal(2))
+		})
+
+		It("recovers providers after their cooldown", func() {
+			primary := &failingEmbedder{fail: true}
+			secondary := &failingEmbedder{}
+			f := embeddings.NewFallbackEmbedderWithOptions([]embeddings.Embedder{primary, secondary},
+				embeddings.WithCooldown(time.Millisecond))
+			_, err := f.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+
+			primary.fail = false
+			time.Sleep(2 * time.Millisecond)
+			_, err = f.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(primary.calls).To(Equal(2))
+			Expect(secondary.calls).To(Equal(1))
+			Expect(f.Health()[0].Healthy).To(BeTrue())
+		})
+
+		It("enforces a single dimension across providers", func() {
+			f := embeddings.NewFallbackEmbedder(testEmbedder{}, embeddings.NewHashEmbedder(8))
+			Expect(f.Verify(context.Background())).To(MatchError(ContainSubstring("dimension 8, expected 3")))
+
+			// secondaries are only called when needed, the primary answering fixes the dimension
+			primary := &failingEmbedder{}
+			secondary := &flakyEmbedder{Embedder: embeddings.NewHashEmbedder(8)}
+			f = embeddings.NewFallbackEmbedder(primary, secondary)
+			_, err := f.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+			primary.fail = true
+			_, err = f.EmbedQuery(context.Background(), "a")
+			Expect(err).To(MatchError(ContainSubstring("dimension 8, expected 3")))
+			Expect(f.Health()[1].Healthy).To(BeFalse())
+		})
+
+		It("ejects providers answering with another dimension and moves on", func() {
+			primary, third := &failingEmbedder{}, &failingEmbedder{}
+			wrong := &flakyEmbedder{Embedder: embeddings.NewHashEmbedder(8)}
+			f := embeddings.NewFallbackEmbedderWithOptions([]embeddings.Embedder{primary, wrong, third},
+				embeddings.WithCooldown(time.Hour), embeddings.WithFailureThreshold(3))
+			_, err := f.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+
+			primary.fail = true
+			e, err := f.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e).To(HaveLen(3))
+			Expect(f.Health()[1].Healthy).To(BeFalse())
+			Expect(f.Health()[1].LastError).To(MatchError(embeddings.DimensionError{Expected: 3, Got: 8}))
+
+			// the mismatching provider is skipped without failing the group
+			e, err = f.EmbedQuery(context.Background(), "a")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e).To(HaveLen(3))
+			Expect(third.calls).To(Equal(2))
+		})
+
+		It("fails calls without panicking when given no embedders", func() {
+			f := embeddings.NewFallbackEmbedderWithOptions(nil)
+			Expect(f.Model()).To(BeEmpty())
+			_, err := f.EmbedQuery(context.Background(), "a")
+			Expect(err).To(HaveOccurred())
+			m := embeddings.NewMultiEmbedder(nil)
+			Expect(m.Model()).To(BeEmpty())
+			_, err = m.EmbedDocuments(context.Background(), []string{"a"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("spreads calls by weight", func() {
+			a, b := &failingEmbedder{}, &failingEmbedder{}
+			m := embeddings.NewMultiEmbedder([]embeddings.Embedder{a, b}, embeddings.WithWeights(3, 1))
+			Expect(m.Verify(context.Background())).To(Succeed())
+			a.calls, b.calls = 0, 0
+			for i := 0; i < 8; i++ {
+				_, err := m.EmbedDocuments(context.Background(), []string{"x"})
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(a.calls).To(Equal(6))
+			Expect(b.calls).To(Equal(2))
+
+			// a failing provider's share moves to the others
+			a.fail = true
+			a.calls, b.calls = 0, 0
+			for i := 0; i < 4; i++ {
+				_, err := m.EmbedQuery(context.Background(), "x")
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(a.calls).To(Equal(1))
+			Expect(b.calls).To(Equal(4))
+		})
+	})
+
+	Describe("usage", func() {
+		It("aggregates tokens, requests and cost per model", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Write([]byte(`{"data": [{"embedding": [1, 2], "index": 0}],
+					"model": "text-embedding-ada-002-v2", "usage": {"prompt_tokens": 8, "total_tokens": 8}}`))
+			}))
+			defer server.Close()
+
+			usage := embeddings.NewUsageAggregator(map[string]float64{"text-embedding-ada-002": 100})
+			openai := embeddings.NewOpenAIClientWithHTTP(server.URL, "", server.Client(),
+				embeddings.WithOpenAIUsageTracker(usage))
+			_, err := openai.EmbedQuery(context.Background(), "foo")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = openai.EmbedDocuments(context.Background(), []string{"foo"})
+			Expect(err).ToNot(HaveOccurred())
+			usage.TrackUsage(context.Background(), embeddings.Usage{Model: "unpriced", Tokens: 5, Requests: 1})
+
+			totals := usage.Totals()
+			Expect(totals).To(HaveLen(2))
+			Expect(totals["text-embedding-ada-002"].Tokens).To(Equal(16))
+			Expect(totals["text-embedding-ada-002"].Requests).To(Equal(2))
+			Expect(totals["text-embedding-ada-002"].EstimatedCost).To(BeNumerically("~", 0.0016, 1e-9))
+			Expect(totals["unpriced"].EstimatedCost).To(BeZero())
+			Expect(usage.EstimatedCost()).To(BeNumerically("~", 0.0016, 1e-9))
+
+			usage.Reset()
+			Expect(usage.Totals()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,314 @@
+package chroma
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SelectionPolicy decides which endpoint of a client with multiple endpoints serves a request
+type SelectionPolicy int
+
+const (
+	// PrimaryFailover sends every request to the first healthy endpoint, in the order they were given
+	PrimaryFailover SelectionPolicy = iota
+	// RoundRobinReads spreads reads over all healthy endpoints and sends writes like PrimaryFailover
+	RoundRobinReads
+)
+
+// WithEndpoints adds servers holding the same data as the client's server url, like replicas.
+// The client's server url is the primary endpoint, the others follow in the order given
+func WithEndpoints(serverURLs ...string) ClientOption {
+	return func(c *Client) {
+		c.endpointURLs = append(c.endpointURLs, serverURLs...)
+	}
+}
+
+// WithSelectionPolicy sets how requests are spread over the client's endpoints. Defaults to PrimaryFailover
+func WithSelectionPolicy(policy SelectionPolicy) ClientOption {
+	return func(c *Client) {
+		c.policy = policy
+	}
+}
+
+// WithWriteFailover lets writes move on to the next endpoint when the primary fails, like reads
+// do. Only use it when the endpoints replicate each other: independent chroma servers end up
+// with writes split between them. By default writes only go to the primary endpoint
+func WithWriteFailover() ClientOption {
+	return func(c *Client) {
+		c.writeFailover = true
+	}
+}
+
+// WithEndpointEjection sets after how many consecutive failed requests an endpoint is ejected, and
+// for how long. Once the cooldown passed the endpoint has to answer a heartbeat before it serves
+// requests again. Defaults to 1 failure and 30s
+func WithEndpointEjection(failures int, cooldown time.Duration) ClientOption {
+	return func(c *Client) {
+		c.ejectAfter = failures
+		c.ejectCooldown = cooldown
+	}
+}
+
+// endpoint is one server of a client
+type endpoint struct {
+	url          string
+	failures     int
+	ejectedUntil time.Time
+	// collections maps collection names to their ids on this endpoint, each server gives its
+	// collections their own ids
+	collections map[string]string
+}
+
+// setEndpoints parses the urls of the client's endpoints, the primary one first
+func (c *Client) setEndpoints(primary *url.URL) error {
+	c.endpoints = []*endpoint{{url: primary.JoinPath("api/v1").String(), collections: map[string]string{}}}
+	for _, raw := range c.endpointURLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		c.endpoints = append(c.endpoints, &endpoint{url: u.JoinPath("api/v1").String(), collections: map[string]string{}})
+	}
+	c.url = c.endpoints[0].url
+	return nil
+}
+
+// route returns the endpoints to try for an operation class in order: endpoints that are not
+// ejected as the policy orders them, then ejected ones as a last resort. Endpoints whose cooldown
+// passed are tried in their usual place but have to answer a heartbeat first. Writes only go to
+// the primary endpoint without WithWriteFailover
+func (c *Client) route(class OperationClass) []route {
+	c.endpointsMu.Lock()
+	defer c.endpointsMu.Unlock()
+	if class == WriteOperations && !c.writeFailover {
+		return []route{{endpoint: c.endpoints[0]}}
+	}
+	now := time.Now()
+	var routes, ejected []route
+	for _, e := range c.endpoints {
+		switch {
+		case e.ejectedUntil.IsZero():
+			routes = append(routes, route{endpoint: e})
+		case now.After(e.ejectedUntil):
+			routes = append(routes, route{endpoint: e, probe: true})
+		default:
+			ejected = append(ejected, route{endpoint: e})
+		}
+	}
+	if c.policy == RoundRobinReads && class == ReadOperations && len(routes) > 1 {
+		start := c.next % len(routes)
+		c.next++
+		routes = append(routes[start:len(routes):len(routes)], routes[:start]...)
+	}
+	return append(routes, ejected...)
+}
+
+type route struct {
+	endpoint *endpoint
+	probe    bool
+}
+
+// record updates the health of e after a request, ejecting it after too many failures
+func (c *Client) record(e *endpoint, failed bool) {
+	c.endpointsMu.Lock()
+	defer c.endpointsMu.Unlock()
+	if !failed {
+		e.failures = 0
+		e.ejectedUntil = time.Time{}
+		return
+	}
+	e.failures++
+	if e.failures >= c.ejectAfter {
+		e.ejectedUntil = time.Now().Add(c.ejectCooldown)
+	}
+}
+
+// send sends a request for path, relative to the api root, to the endpoints chosen by the client's
+// policy. Requests failing without a response move on to the next endpoint, reads also do when the
+// endpoint is unavailable. Other error responses are chroma's answer and returned as they are.
+// Collection requests are sent with the collection's id on each endpoint
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, r RequestInfo) (*http.Response, error) {
+	if len(c.endpoints) == 1 {
+		return c.sendTo(ctx, c.endpoints[0], method, path, body, r)
+	}
+	if c.breaker == nil || r.probe {
+		return c.failover(ctx, method, path, body, r)
+	}
+	// the breaker guards the request as a whole, failures another endpoint recovered from don't count
+	if err := c.breaker.allow(ctx, c.probe); err != nil {
+		return nil, err
+	}
+	resp, err := c.failover(ctx, method, path, body, r)
+	if ctx.Err() == nil {
+		result := RequestResult{Err: err}
+		if resp != nil {
+			result.StatusCode = resp.StatusCode
+		}
+		c.breaker.record(result)
+	}
+	return resp, err
+}
+
+// failover sends a request to the endpoints chosen by the client's policy, see send
+func (c *Client) failover(ctx context.Context, method, path string, body io.Reader, r RequestInfo) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+	routes := c.route(r.Operation.Class())
+	collection, routed := c.collectionName(r.CollectionID)
+	var errs []error
+	for i, route := range routes {
+		e := route.endpoint
+		if route.probe {
+			if err := c.heartbeat(ctx, e); err != nil {
+				c.record(e, true)
+				errs = append(errs, err)
+				continue
+			}
+		}
+		endpointPath := path
+		if routed {
+			id, err := c.collectionID(ctx, e, collection)
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			endpointPath = "/collections/" + id + strings.TrimPrefix(path, "/collections/"+r.CollectionID)
+		}
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		resp, err := c.sendTo(ctx, e, method, endpointPath, body, r)
+		if ctx.Err() != nil {
+			// the caller gave up, that says nothing about the endpoint's health
+			return resp, err
+		}
+		unavailable := err == nil && unavailableStatus(resp.StatusCode)
+		c.record(e, err != nil || unavailable)
+		if err == nil && (!unavailable || r.Operation.Class() == WriteOperations || i == len(routes)-1) {
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = &StatusError{StatusCode: resp.StatusCode}
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// rememberCollection records the name of a collection returned by a client with multiple
+// endpoints, to find the collection on every endpoint
+func (c *Client) rememberCollection(collection Collection) {
+	if len(c.endpoints) == 1 {
+		return
+	}
+	c.endpointsMu.Lock()
+	defer c.endpointsMu.Unlock()
+	c.collectionNames[collection.ID] = collection.Name
+}
+
+// forgetCollections drops the ids of the collection called name, or of all collections when
+// name is empty, after they were deleted
+func (c *Client) forgetCollections(name string) {
+	c.endpointsMu.Lock()
+	defer c.endpointsMu.Unlock()
+	for _, e := range c.endpoints {
+		if name == "" {
+			e.collections = map[string]string{}
+		} else {
+			delete(e.collections, name)
+		}
+	}
+}
+
+// collectionName returns the name of the collection with the given id, for clients with
+// multiple endpoints
+func (c *Client) collectionName(id string) (string, bool) {
+	if id == "" {
+		return "", false
+	}
+	c.endpointsMu.Lock()
+	defer c.endpointsMu.Unlock()
+	name, ok := c.collectionNames[id]
+	return name, ok
+}
+
+// collectionID returns the id of the collection called name on endpoint e, getting it from the
+// endpoint the first time
+func (c *Client) collectionID(ctx context.Context, e *endpoint, name string) (string, error) {
+	c.endpointsMu.Lock()
+	id, ok := e.collections[name]
+	c.endpointsMu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	resp, err := c.sendTo(ctx, e, http.MethodGet, "/collections/"+name, nil, RequestInfo{Operation: OpGetCollection})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	collection := struct {
+		ID    string `json:"id"`
+		Error string `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return "", err
+	}
+	if collection.ID == "" {
+		return "", fmt.Errorf("error getting collection %s from %s: %s", name, e.url, collection.Error)
+	}
+	c.endpointsMu.Lock()
+	e.collections[name] = collection.ID
+	c.endpointsMu.Unlock()
+	return collection.ID, nil
+}
+
+// unavailableStatus reports whether status means the endpoint can't serve requests right now
+func unavailableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// heartbeat checks that an ejected endpoint answers again
+func (c *Client) heartbeat(ctx context.Context, e *endpoint) error {
+	resp, err := c.sendTo(ctx, e, http.MethodGet, "/heartbeat", nil, RequestInfo{Operation: OpHeartbeat, probe: true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// sendTo builds a request for path on endpoint e and sends it
+func (c *Client) sendTo(ctx context.Context, e *endpoint, method, path string, body io.Reader,
+	r RequestInfo) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, e.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, r)
+}
@@ -0,0 +1,169 @@
+package chroma_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+var _ = Describe("Multiple endpoints", func() {
+	var primary, secondary *fakeChroma
+	var primaryDown atomic.Bool
+	var primaryProxy *httptest.Server
+
+	BeforeEach(func() {
+		primary, secondary = newFakeChroma(), newFakeChroma()
+		primaryDown.Store(false)
+		// the primary answers 503 while down, like a chroma behind a load balancer
+		primaryProxy = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if primaryDown.Load() {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			primary.Config.Handler.ServeHTTP(rw, req)
+		}))
+		// both servers hold the same collection, as replicas would
+		for _, server := range []*fakeChroma{primary, secondary} {
+			client, err := chroma.NewClient(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.CreateCollection("replicated", "l2", nil)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		primaryProxy.Close()
+		primary.Close()
+		secondary.Close()
+	})
+
+	It("fails reads over to the secondary while the primary is down", func() {
+		client, err := chroma.NewClient(primaryProxy.URL, chroma.WithEndpoints(secondary.URL),
+			chroma.WithEndpointEjection(1, 50*time.Millisecond))
+		Expect(err).ToNot(HaveOccurred())
+		collection, err := client.GetCollection("replicated")
+		Expect(err).ToNot(HaveOccurred())
+		primaryID, secondaryID := primary.byName("replicated").ID, secondary.byName("replicated").ID
+		Expect(collection.ID).To(Equal(primaryID))
+		Expect(primaryID).ToNot(Equal(secondaryID))
+		Expect(collection.Add([]chroma.Document{{ID: "1", Content: "a"}}, testEmbedder{})).To(Succeed())
+		Expect(primary.Requests()).To(ContainElement("POST /collections/" + primaryID + "/add"))
+
+		primaryDown.Store(true)
+		// reads move on to the secondary right away, under the secondary's id, and eject the primary
+		Expect(collection.Count()).To(Equal(0))
+		Expect(secondary.Requests()).To(ContainElements("GET /collections/replicated",
+			"GET /collections/"+secondaryID+"/count"))
+		// writes stay on the primary
+		Expect(collection.Add([]chroma.Document{{ID: "2", Content: "b"}}, testEmbedder{})).ToNot(Succeed())
+		Expect(secondary.byName("replicated").docs).To(BeEmpty())
+
+		// once the cooldown passed the primary serves again after answering a heartbeat
+		primaryDown.Store(false)
+		time.Sleep(60 * time.Millisecond)
+		sent := len(primary.Requests())
+		Expect(collection.Count()).To(Equal(1))
+		Expect(primary.Requests()[sent:]).To(Equal([]string{"GET /heartbeat", "GET /collections/" + primaryID + "/count"}))
+	})
+
+	It("fails writes over when asked to, splitting them between independent servers", func() {
+		client, err := chroma.NewClient(primaryProxy.URL, chroma.WithEndpoints(secondary.URL),
+			chroma.WithWriteFailover())
+		Expect(err).ToNot(HaveOccurred())
+		collection, err := client.GetCollection("replicated")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Add([]chroma.Document{{ID: "1", Content: "a"}}, testEmbedder{})).To(Succeed())
+
+		// writes only move on after failures without a response, chroma may have applied them otherwise
+		primaryProxy.Close()
+		Expect(collection.Add([]chroma.Document{{ID: "2", Content: "b"}}, testEmbedder{})).To(Succeed())
+		Expect(primary.byName("replicated").docs).To(HaveLen(1))
+		Expect(secondary.byName("replicated").docs).To(HaveLen(1))
+		Expect(secondary.byName("replicated").docs[0].ID).To(Equal("2"))
+	})
+
+	It("spreads reads over endpoints and sends writes to the primary", func() {
+		client, err := chroma.NewClient(primaryProxy.URL, chroma.WithEndpoints(secondary.URL),
+			chroma.WithSelectionPolicy(chroma.RoundRobinReads))
+		Expect(err).ToNot(HaveOccurred())
+		primarySent, secondarySent := len(primary.Requests()), len(secondary.Requests())
+		for i := 0; i < 4; i++ {
+			_, err = client.Heartbeat()
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(primary.Requests()[primarySent:]).To(HaveLen(2))
+		Expect(secondary.Requests()[secondarySent:]).To(HaveLen(2))
+
+		_, err = client.CreateCollection("written", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(primary.Requests()).To(ContainElement("POST /collections"))
+		Expect(secondary.Requests()[secondarySent:]).ToNot(ContainElement("POST /collections"))
+	})
+
+	It("doesn't eject endpoints while the circuit breaker is open", func() {
+		breaker := chroma.NewCircuitBreaker(chroma.WithConsecutiveFailures(1),
+			chroma.WithOpenTimeout(50*time.Millisecond))
+		client, err := chroma.NewClient(primaryProxy.URL, chroma.WithEndpoints(secondary.URL),
+			chroma.WithCircuitBreaker(breaker), chroma.WithEndpointEjection(1, 10*time.Millisecond))
+		Expect(err).ToNot(HaveOccurred())
+
+		// a 500 is chroma's answer, it opens the breaker without ejecting the primary
+		primary.SetFailing(true)
+		_, err = client.Heartbeat()
+		Expect(err).To(HaveOccurred())
+		Expect(breaker.State()).To(Equal(chroma.CircuitOpen))
+		primary.SetFailing(false)
+
+		primarySent, secondarySent := len(primary.Requests()), len(secondary.Requests())
+		_, err = client.Heartbeat()
+		Expect(err).To(MatchError(chroma.ErrCircuitOpen))
+		Expect(primary.Requests()).To(HaveLen(primarySent))
+		Expect(secondary.Requests()).To(HaveLen(secondarySent))
+
+		// the primary still serves without an ejection heartbeat once the breaker closes
+		Eventually(breaker.State).Should(Equal(chroma.CircuitHalfOpen))
+		_, err = client.Heartbeat()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(breaker.State()).To(Equal(chroma.CircuitClosed))
+		_, err = client.Heartbeat()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(primary.Requests()[primarySent:]).To(Equal([]string{"GET /heartbeat", "GET /heartbeat", "GET /heartbeat"}))
+		Expect(secondary.Requests()).To(HaveLen(secondarySent))
+	})
+
+	It("doesn't count failures another endpoint recovered from against the circuit breaker", func() {
+		breaker := chroma.NewCircuitBreaker(chroma.WithConsecutiveFailures(1))
+		client, err := chroma.NewClient(primaryProxy.URL, chroma.WithEndpoints(secondary.URL),
+			chroma.WithCircuitBreaker(breaker), chroma.WithEndpointEjection(1, time.Millisecond))
+		Expect(err).ToNot(HaveOccurred())
+
+		primaryDown.Store(true)
+		for i := 0; i < 3; i++ {
+			time.Sleep(2 * time.Millisecond)
+			_, err = client.Heartbeat()
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(breaker.State()).To(Equal(chroma.CircuitClosed))
+
+		// it still opens once no endpoint answers
+		secondary.SetFailing(true)
+		_, err = client.Heartbeat()
+		Expect(err).To(HaveOccurred())
+		Expect(breaker.State()).To(Equal(chroma.CircuitOpen))
+	})
+
+	It("returns every endpoint's error when none answers", func() {
+		client, err := chroma.NewClient(primaryProxy.URL, chroma.WithEndpoints(secondary.URL))
+		Expect(err).ToNot(HaveOccurred())
+		primaryDown.Store(true)
+		secondary.Close()
+		_, err = client.Heartbeat()
+		Expect(err).To(MatchError(ContainSubstring("503")))
+		Expect(err).To(MatchError(ContainSubstring("connection refused")))
+	})
+})
@@ -0,0 +1,128 @@
+package chroma
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// ExportVersion is the version of the export format written by Collection.Export
+const ExportVersion = 1
+
+// ExportHeader is the first line of an export, describing the exported collection
+type ExportHeader struct {
+	Version    int                `json:"version"`
+	Collection ExportedCollection `json:"collection"`
+}
+
+// ExportedCollection describes the exported collection
+type ExportedCollection struct {
+	Name       string         `json:"name"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	DistanceFn string         `json:"distanceFn"`
+}
+
+// ExportRecord is a line of an export holding a single document
+type ExportRecord struct {
+	ID        string         `json:"id"`
+	Content   string         `json:"content"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	Embedding []float32      `json:"embedding,omitempty"`
+}
+
+// ExportOptions configures Collection.Export
+type ExportOptions struct {
+	// BatchSize is the number of documents fetched per request. Defaults to 100
+	BatchSize int
+	// Gzip compresses the export
+	Gzip bool
+	// Where and WhereDocument only export the matching documents
+	Where         map[string]any
+	WhereDocument map[string]any
+}
+
+// Export writes a header line and every document of the collection with its embedding to w as
+// JSON Lines, fetching documents in pages. It returns the number of documents written. Documents
+// added or deleted while the export runs may be missed or exported twice
+func (c Collection) Export(ctx context.Context, w io.Writer, opts ExportOptions) (int, error) {
+	if opts.Gzip {
+		zw := gzip.NewWriter(w)
+		n, err := c.export(ctx, zw, opts)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		return n, err
+	}
+	return c.export(ctx, w, opts)
+}
+
+func (c Collection) export(ctx context.Context, w io.Writer, opts ExportOptions) (int, error) {
+	enc := json.NewEncoder(w)
+	err := enc.Encode(ExportHeader{
+		Version:    ExportVersion,
+		Collection: ExportedCollection{Name: c.Name, Metadata: c.Metadata, DistanceFn: c.DistanceFn},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	err = c.Scan(ctx, ScanOptions{BatchSize: opts.BatchSize, Where: opts.Where, WhereDocument: opts.WhereDocument},
+		func(docs []Document) error {
+			for _, doc := range docs {
+				err := enc.Encode(ExportRecord{
+					ID: doc.ID, Content: doc.Content, Metadata: doc.Metadata, Embedding: doc.Embeddings,
+				})
+				if err != nil {
+					return err
+				}
+				exported++
+			}
+			return nil
+		})
+	return exported, err
+}
+
+// ScanOptions configures Collection.Scan
+type ScanOptions struct {
+	// BatchSize is the number of documents fetched per request. Defaults to 100
+	BatchSize int
+	// Where and WhereDocument only scan the matching documents
+	Where         map[string]any
+	WhereDocument map[string]any
+	// Include lists the fields fetched besides ids. Defaults to documents, metadatas and embeddings
+	// when nil, an empty list only fetches ids
+	Include []QueryEnum
+}
+
+// Scan fetches the documents of the collection in pages and calls fn with every page, stopping at
+// the first error. Documents added or deleted while the scan runs may be missed or seen twice
+func (c Collection) Scan(ctx context.Context, opts ScanOptions, fn func(docs []Document) error) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Include == nil {
+		opts.Include = []QueryEnum{WithDocuments, WithMetadatas, WithEmbeddings}
+	}
+	for offset := 0; ; offset += opts.BatchSize {
+		docs, err := c.get(ctx, map[string]any{
+			"where":          opts.Where,
+			"where_document": opts.WhereDocument,
+			"limit":          opts.BatchSize,
+			"offset":         offset,
+			"include":        opts.Include,
+		})
+		if err != nil {
+			return err
+		}
+		if len(docs) > 0 {
+			if err := fn(docs); err != nil {
+				return err
+			}
+		}
+		if len(docs) < opts.BatchSize {
+			return nil
+		}
+	}
+}
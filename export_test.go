@@ -0,0 +1,93 @@
+package chroma_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+// exportLines decodes every line of an export
+func exportLines(r io.Reader) []map[string]any {
+	var lines []map[string]any
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := map[string]any{}
+		Expect(json.Unmarshal(scanner.Bytes(), &line)).To(Succeed())
+		lines = append(lines, line)
+	}
+	Expect(scanner.Err()).ToNot(HaveOccurred())
+	return lines
+}
+
+var _ = Describe("Export", func() {
+	var server *fakeChroma
+	var collection chroma.Collection
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+		client, err := chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		collection = seedCollection(client, "exported", 5)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("writes a header and every document in pages", func() {
+		buf := &bytes.Buffer{}
+		n, err := collection.Export(context.Background(), buf, chroma.ExportOptions{BatchSize: 2})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(5))
+
+		lines := exportLines(buf)
+		Expect(lines).To(HaveLen(6))
+		Expect(lines[0]).To(HaveKeyWithValue("version", 1.0))
+		Expect(lines[0]).To(HaveKeyWithValue("collection", map[string]any{
+			"name": "exported", "distanceFn": "cosine",
+			"metadata": map[string]any{"team": "search", "hnsw:space": "cosine"},
+		}))
+		Expect(lines[1]).To(Equal(map[string]any{
+			"id": "0", "content": "doc 0", "metadata": map[string]any{"n": 0.0, "even": true}, "embedding": []any{0.0, 1.0},
+		}))
+		Expect(lines[5]).To(HaveKeyWithValue("id", "4"))
+
+		gets := 0
+		for _, req := range server.Requests() {
+			if req == "POST /collections/"+collection.ID+"/get" {
+				gets++
+			}
+		}
+		Expect(gets).To(Equal(3))
+	})
+
+	It("compresses and filters exports", func() {
+		buf := &bytes.Buffer{}
+		n, err := collection.Export(context.Background(), buf, chroma.ExportOptions{
+			Gzip: true, Where: map[string]any{"even": true},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(3))
+
+		zr, err := gzip.NewReader(buf)
+		Expect(err).ToNot(HaveOccurred())
+		lines := exportLines(zr)
+		Expect(lines).To(HaveLen(4))
+		Expect(lines[1]).To(HaveKeyWithValue("id", "0"))
+		Expect(lines[2]).To(HaveKeyWithValue("id", "2"))
+		Expect(lines[3]).To(HaveKeyWithValue("id", "4"))
+	})
+
+	It("fails when a page can't be fetched", func() {
+		server.SetFailing(true)
+		_, err := collection.Export(context.Background(), io.Discard, chroma.ExportOptions{})
+		Expect(err).To(MatchError(ContainSubstring("error getting documents")))
+	})
+})
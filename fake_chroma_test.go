@@ -0,0 +1,438 @@
+package chroma_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+// fakeChroma is an in-memory stand-in for the subset of the chroma 0.4 http api used by the client,
+// so specs can run without a chroma server
+type fakeChroma struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	collections []*fakeCollection
+	requests    []string // "METHOD path" of every request served
+	failing     bool     // when set every request fails with a 500
+}
+
+type fakeCollection struct {
+	ID       string
+	Name     string
+	Metadata map[string]any
+	docs     []fakeDoc
+}
+
+type fakeDoc struct {
+	ID        string
+	Embedding []float32
+	Metadata  map[string]any
+	Document  string
+}
+
+// fakeCollectionIDs numbers collections across all fake servers, so like independent chroma
+// servers they never give two collections the same id
+var fakeCollectionIDs atomic.Int64
+
+func newFakeChroma() *fakeChroma {
+	f := &fakeChroma{}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// seedCollection creates a cosine collection called name holding n documents with ids "0" to
+// n-1, each with an "n" and an "even" metadata key and a 2 dimensional embedding
+func seedCollection(client chroma.Chroma, name string, n int) chroma.Collection {
+	collection, err := client.CreateCollection(name, "cosine", map[string]any{"team": "search"})
+	Expect(err).ToNot(HaveOccurred())
+	var docs []chroma.Document
+	for i := 0; i < n; i++ {
+		docs = append(docs, chroma.Document{
+			ID:         strconv.Itoa(i),
+			Content:    "doc " + strconv.Itoa(i),
+			Metadata:   map[string]any{"n": i, "even": i%2 == 0},
+			Embeddings: []float32{float32(i), 1},
+		})
+	}
+	Expect(collection.Add(docs, nil)).To(Succeed())
+	return collection
+}
+
+// Requests returns the requests served so far
+func (f *fakeChroma) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+// SetFailing makes the server fail or serve every following request
+func (f *fakeChroma) SetFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func (f *fakeChroma) byName(name string) *fakeCollection {
+	for _, c := range f.collections {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (f *fakeChroma) byID(id string) *fakeCollection {
+	for _, c := range f.collections {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (c *fakeCollection) index(id string) int {
+	for i, d := range c.docs {
+		if d.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(v)
+}
+
+func writeError(rw http.ResponseWriter, format string, args ...any) {
+	writeJSON(rw, http.StatusInternalServerError, map[string]any{"error": fmt.Sprintf(format, args...)})
+}
+
+func (f *fakeChroma) serve(rw http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(req.URL.Path, "/api/v1")
+	f.requests = append(f.requests, req.Method+" "+path)
+	if f.failing {
+		writeError(rw, "fake server failure")
+		return
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	body := map[string]any{}
+	if req.Body != nil && (req.Method == http.MethodPost || req.Method == http.MethodPut) {
+		json.NewDecoder(req.Body).Decode(&body)
+	}
+
+	switch {
+	case path == "/heartbeat":
+		writeJSON(rw, http.StatusOK, map[string]int{"nanosecond heartbeat": 1700000000000000000})
+	case path == "/version":
+		rw.Write([]byte(`"0.4.14"`))
+	case path == "/reset":
+		f.collections = nil
+		rw.Write([]byte("true"))
+	case path == "/collections" && req.Method == http.MethodGet:
+		var out []map[string]any
+		for _, c := range f.collections {
+			out = append(out, map[string]any{"id": c.ID, "name": c.Name, "metadata": c.Metadata})
+		}
+		writeJSON(rw, http.StatusOK, out)
+	case path == "/collections" && req.Method == http.MethodPost:
+		name, _ := body["name"].(string)
+		metadata, _ := body["metadata"].(map[string]any)
+		if c := f.byName(name); c != nil {
+			if body["get_or_create"] != true {
+				writeError(rw, "ValueError('Collection %s already exists.')", name)
+				return
+			}
+			writeJSON(rw, http.StatusOK, map[string]any{"id": c.ID, "name": c.Name, "metadata": c.Metadata})
+			return
+		}
+		c := &fakeCollection{ID: fmt.Sprintf("id-%d", fakeCollectionIDs.Add(1)), Name: name, Metadata: metadata}
+		f.collections = append(f.collections, c)
+		writeJSON(rw, http.StatusOK, map[string]any{"id": c.ID, "name": c.Name, "metadata": c.Metadata})
+	case len(parts) == 2 && req.Method == http.MethodGet:
+		c := f.byName(parts[1])
+		if c == nil {
+			writeError(rw, "ValueError('Collection %s does not exist.')", parts[1])
+			return
+		}
+		writeJSON(rw, http.StatusOK, map[string]any{"id": c.ID, "name": c.Name, "metadata": c.Metadata})
+	case len(parts) == 2 && req.Method == http.MethodDelete:
+		for i, c := range f.collections {
+			if c.Name == parts[1] {
+				f.collections = append(f.collections[:i], f.collections[i+1:]...)
+				writeJSON(rw, http.StatusOK, map[string]any{})
+				return
+			}
+		}
+		writeError(rw, "ValueError('Collection %s does not exist.')", parts[1])
+	case len(parts) == 2 && req.Method == http.MethodPut:
+		c := f.byID(parts[1])
+		if c == nil {
+			writeError(rw, "ValueError('Collection %s does not exist.')", parts[1])
+			return
+		}
+		if name, ok := body["new_name"].(string); ok {
+			c.Name = name
+		}
+		if metadata, ok := body["new_metadata"].(map[string]any); ok {
+			c.Metadata = metadata
+		}
+		writeJSON(rw, http.StatusOK, map[string]any{})
+	case len(parts) == 3:
+		c := f.byID(parts[1])
+		if c == nil {
+			writeError(rw, "ValueError('Collection %s does not exist.')", parts[1])
+			return
+		}
+		f.serveCollection(rw, c, parts[2], body)
+	default:
+		writeJSON(rw, http.StatusNotFound, map[string]any{"detail": "Not Found"})
+	}
+}
+
+func (f *fakeChroma) serveCollection(rw http.ResponseWriter, c *fakeCollection, op string, body map[string]any) {
+	switch op {
+	case "count":
+		rw.Write([]byte(strconv.Itoa(len(c.docs))))
+	case "add", "upsert":
+		docs := decodeFakeDocs(body)
+		for _, d := range docs {
+			if i := c.index(d.ID); i >= 0 {
+				if op == "add" {
+					writeError(rw, "IDAlreadyExistsError('ID %s already exists')", d.ID)
+					return
+				}
+				c.docs[i] = d
+				continue
+			}
+			c.docs = append(c.docs, d)
+		}
+		writeJSON(rw, http.StatusCreated, true)
+	case "delete":
+		matched := c.filter(body)
+		var kept []fakeDoc
+		var deleted []string
+		for _, d := range c.docs {
+			if matched[d.ID] {
+				deleted = append(deleted, d.ID)
+				continue
+			}
+			kept = append(kept, d)
+		}
+		c.docs = kept
+		writeJSON(rw, http.StatusOK, deleted)
+	case "get":
+		include := includes(body, []string{"metadatas", "documents"})
+		matched := c.filter(body)
+		var docs []fakeDoc
+		for _, d := range c.docs {
+			if matched[d.ID] {
+				docs = append(docs, d)
+			}
+		}
+		if offset, ok := body["offset"].(float64); ok {
+			docs = docs[min(int(offset), len(docs)):]
+		}
+		if limit, ok := body["limit"].(float64); ok {
+			docs = docs[:min(int(limit), len(docs))]
+		}
+		out := map[string]any{"ids": []string{}, "embeddings": nil, "metadatas": nil, "documents": nil}
+		var ids, documents []string
+		var embeddings [][]float32
+		var metadatas []map[string]any
+		for _, d := range docs {
+			ids = append(ids, d.ID)
+			documents = append(documents, d.Document)
+			embeddings = append(embeddings, d.Embedding)
+			metadatas = append(metadatas, d.Metadata)
+		}
+		if ids != nil {
+			out["ids"] = ids
+		}
+		if include["documents"] {
+			out["documents"] = documents
+		}
+		if include["embeddings"] {
+			out["embeddings"] = embeddings
+		}
+		if include["metadatas"] {
+			out["metadatas"] = metadatas
+		}
+		writeJSON(rw, http.StatusOK, out)
+	case "query":
+		include := includes(body, []string{"metadatas", "documents", "distances"})
+		queries, _ := body["query_embeddings"].([]any)
+		n := 10
+		if nr, ok := body["n_results"].(float64); ok {
+			n = int(nr)
+		}
+		matched := c.filter(body)
+		out := map[string][]any{}
+		for _, q := range queries {
+			query := toFloats(q)
+			type hit struct {
+				doc      fakeDoc
+				distance float64
+			}
+			var hits []hit
+			for _, d := range c.docs {
+				if !matched[d.ID] {
+					continue
+				}
+				var dist float64
+				for i := range query {
+					if i < len(d.Embedding) {
+						diff := float64(query[i] - d.Embedding[i])
+						dist += diff * diff
+					}
+				}
+				hits = append(hits, hit{d, dist})
+			}
+			sort.SliceStable(hits, func(i, j int) bool { return hits[i].distance < hits[j].distance })
+			if len(hits) > n {
+				hits = hits[:n]
+			}
+			var ids, documents []string
+			var distances []float64
+			var embeddings [][]float32
+			var metadatas []map[string]any
+			for _, h := range hits {
+				ids = append(ids, h.doc.ID)
+				documents = append(documents, h.doc.Document)
+				distances = append(distances, h.distance)
+				embeddings = append(embeddings, h.doc.Embedding)
+				metadatas = append(metadatas, h.doc.Metadata)
+			}
+			out["ids"] = append(out["ids"], ids)
+			out["documents"] = append(out["documents"], documents)
+			out["distances"] = append(out["distances"], distances)
+			out["embeddings"] = append(out["embeddings"], embeddings)
+			out["metadatas"] = append(out["metadatas"], metadatas)
+		}
+		result := map[string]any{"ids": out["ids"]}
+		for _, field := range []string{"documents", "distances", "embeddings", "metadatas"} {
+			if include[field] {
+				result[field] = out[field]
+			} else {
+				result[field] = nil
+			}
+		}
+		writeJSON(rw, http.StatusOK, result)
+	default:
+		writeJSON(rw, http.StatusNotFound, map[string]any{"detail": "Not Found"})
+	}
+}
+
+// filter returns the ids of all documents matching the ids, where and where_document clauses of body.
+// where only supports equality, $eq and $in; where_document only supports $contains
+func (c *fakeCollection) filter(body map[string]any) map[string]bool {
+	var ids map[string]bool
+	if rawIDs, ok := body["ids"].([]any); ok {
+		ids = map[string]bool{}
+		for _, id := range rawIDs {
+			ids[id.(string)] = true
+		}
+	}
+	where, _ := body["where"].(map[string]any)
+	whereDocument, _ := body["where_document"].(map[string]any)
+
+	matched := map[string]bool{}
+	for _, d := range c.docs {
+		if ids != nil && !ids[d.ID] {
+			continue
+		}
+		if !matchesWhere(d.Metadata, where) {
+			continue
+		}
+		if contains, ok := whereDocument["$contains"].(string); ok && !strings.Contains(d.Document, contains) {
+			continue
+		}
+		matched[d.ID] = true
+	}
+	return matched
+}
+
+func matchesWhere(metadata map[string]any, where map[string]any) bool {
+	for key, cond := range where {
+		value := metadata[key]
+		switch c := cond.(type) {
+		case map[string]any:
+			if eq, ok := c["$eq"]; ok && value != eq {
+				return false
+			}
+			if in, ok := c["$in"].([]any); ok {
+				found := false
+				for _, v := range in {
+					found = found || v == value
+				}
+				if !found {
+					return false
+				}
+			}
+		default:
+			if value != cond {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func includes(body map[string]any, defaults []string) map[string]bool {
+	include := map[string]bool{}
+	raw, ok := body["include"].([]any)
+	if !ok {
+		for _, field := range defaults {
+			include[field] = true
+		}
+		return include
+	}
+	for _, field := range raw {
+		include[field.(string)] = true
+	}
+	return include
+}
+
+func decodeFakeDocs(body map[string]any) []fakeDoc {
+	ids, _ := body["ids"].([]any)
+	embeddings, _ := body["embeddings"].([]any)
+	metadatas, _ := body["metadatas"].([]any)
+	documents, _ := body["documents"].([]any)
+	docs := make([]fakeDoc, len(ids))
+	for i, id := range ids {
+		docs[i].ID = id.(string)
+		if i < len(embeddings) {
+			docs[i].Embedding = toFloats(embeddings[i])
+		}
+		if i < len(metadatas) {
+			docs[i].Metadata, _ = metadatas[i].(map[string]any)
+		}
+		if i < len(documents) {
+			docs[i].Document, _ = documents[i].(string)
+		}
+	}
+	return docs
+}
+
+func toFloats(v any) []float32 {
+	raw, _ := v.([]any)
+	floats := make([]float32, len(raw))
+	for i, f := range raw {
+		floats[i] = float32(f.(float64))
+	}
+	return floats
+}
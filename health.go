@@ -0,0 +1,275 @@
+package chroma
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthStatus is the outcome of the latest check of a HealthMonitor
+type HealthStatus struct {
+	Healthy bool
+	Version string
+	// Latency is the round trip time of the heartbeat request
+	Latency time.Duration
+	// ClockSkew is how far the server's clock is ahead of the local one, estimated from the heartbeat
+	ClockSkew           time.Duration
+	CheckedAt           time.Time
+	ConsecutiveFailures int
+	Err                 error
+}
+
+// HealthMonitor polls a server's heartbeat and version in the background
+type HealthMonitor struct {
+	client         Chroma
+	interval       time.Duration
+	unhealthyAfter int
+	maxClockSkew   time.Duration
+	checkTimeout   time.Duration
+
+	mu          sync.Mutex
+	status      HealthStatus
+	subscribers map[chan HealthStatus]struct{}
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// MonitorOption configures a HealthMonitor
+type MonitorOption func(*HealthMonitor)
+
+// WithInterval sets how often the server is checked. Defaults to 10s
+func WithInterval(interval time.Duration) MonitorOption {
+	return func(m *HealthMonitor) {
+		m.interval = interval
+	}
+}
+
+// WithUnhealthyAfter sets after how many consecutive failed checks the server is unhealthy. Defaults to 1
+func WithUnhealthyAfter(failures int) MonitorOption {
+	return func(m *HealthMonitor) {
+		m.unhealthyAfter = failures
+	}
+}
+
+// WithMaxClockSkew fails checks when the server's clock is further off than maxSkew. Skew is
+// not checked by default
+func WithMaxClockSkew(maxSkew time.Duration) MonitorOption {
+	return func(m *HealthMonitor) {
+		m.maxClockSkew = maxSkew
+	}
+}
+
+// WithCheckTimeout fails checks that take longer than timeout, so a server that stops answering
+// is reported unhealthy instead of hanging the monitor. Defaults to 5s
+func WithCheckTimeout(timeout time.Duration) MonitorOption {
+	return func(m *HealthMonitor) {
+		m.checkTimeout = timeout
+	}
+}
+
+// NewHealthMonitor returns a monitor of client. It reports the server unhealthy until Start or
+// Check ran the first check
+func NewHealthMonitor(client Chroma, opts ...MonitorOption) *HealthMonitor {
+	m := &HealthMonitor{
+		client:         client,
+		interval:       10 * time.Second,
+		unhealthyAfter: 1,
+		checkTimeout:   5 * time.Second,
+		subscribers:    map[chan HealthStatus]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Start checks the server now and then at every interval until Stop is called
+func (m *HealthMonitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop, m.done = make(chan struct{}), make(chan struct{})
+	go m.run(m.stop, m.done)
+}
+
+// Stop stops the background checks and waits for a running check to finish
+func (m *HealthMonitor) Stop() {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (m *HealthMonitor) run(stop, done chan struct{}) {
+	defer close(done)
+	// Stop cancels a running check
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.check(ctx)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check checks the server once, updates the monitor's status and returns it
+func (m *HealthMonitor) Check() HealthStatus {
+	return m.check(context.Background())
+}
+
+func (m *HealthMonitor) check(ctx context.Context) HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, m.checkTimeout)
+	defer cancel()
+	start := time.Now()
+	beat, err := m.heartbeat(ctx)
+	latency := time.Since(start)
+	var version string
+	if err == nil {
+		version, err = m.version(ctx)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := HealthStatus{Version: version, Latency: latency, CheckedAt: time.Now()}
+	if err == nil {
+		// the server read its clock about half way through the request
+		status.ClockSkew = time.Unix(0, int64(beat)).Sub(start.Add(latency / 2))
+		if m.maxClockSkew > 0 && status.ClockSkew.Abs() > m.maxClockSkew {
+			err = fmt.Errorf("server clock is off by %s", status.ClockSkew)
+		}
+	}
+	status.Err = err
+	if err != nil {
+		status.ConsecutiveFailures = m.status.ConsecutiveFailures + 1
+		status.Healthy = m.status.Healthy && status.ConsecutiveFailures < m.unhealthyAfter
+	} else {
+		status.Healthy = true
+	}
+
+	changed := status.Healthy != m.status.Healthy || m.status.CheckedAt.IsZero()
+	m.status = status
+	if changed {
+		for ch := range m.subscribers {
+			// drop the pending status a slow subscriber didn't receive yet, it is outdated
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- status
+		}
+	}
+	return status
+}
+
+// heartbeat gets the heartbeat of the monitored server, giving up when ctx is done
+func (m *HealthMonitor) heartbeat(ctx context.Context) (int, error) {
+	if c, ok := m.client.(*Client); ok {
+		return c.heartbeatContext(ctx)
+	}
+	return withContext(ctx, m.client.Heartbeat)
+}
+
+// version gets the version of the monitored server, giving up when ctx is done
+func (m *HealthMonitor) version(ctx context.Context) (string, error) {
+	if c, ok := m.client.(*Client); ok {
+		return c.versionContext(ctx)
+	}
+	return withContext(ctx, m.client.GetVersion)
+}
+
+// withContext returns the result of call, or the error of ctx when it is done first. The call
+// keeps running in the background then, clients without context support can't be interrupted
+func withContext[T any](ctx context.Context, call func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := call()
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Healthy reports whether the server passed the latest check
+func (m *HealthMonitor) Healthy() bool {
+	return m.Status().Healthy
+}
+
+// Status returns the outcome of the latest check
+func (m *HealthMonitor) Status() HealthStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Subscribe returns a channel receiving the status whenever the server turns healthy or unhealthy,
+// starting with the first check. Subscribers only ever see the latest change. Call unsubscribe to
+// close the channel once done
+func (m *HealthMonitor) Subscribe() (changes <-chan HealthStatus, unsubscribe func()) {
+	ch := make(chan HealthStatus, 1)
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			delete(m.subscribers, ch)
+			close(ch)
+		})
+	}
+}
+
+// ServeHTTP reports the latest status as json, with status 200 when healthy and 503 otherwise,
+// for readiness probes
+func (m *HealthMonitor) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+	status := m.Status()
+	body := map[string]any{
+		"healthy":             status.Healthy,
+		"version":             status.Version,
+		"latencyMs":           status.Latency.Milliseconds(),
+		"clockSkewMs":         status.ClockSkew.Milliseconds(),
+		"consecutiveFailures": status.ConsecutiveFailures,
+	}
+	if !status.CheckedAt.IsZero() {
+		body["checkedAt"] = status.CheckedAt
+	}
+	if status.Err != nil {
+		body["error"] = status.Err.Error()
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if !status.Healthy {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(rw).Encode(body)
+}
@@ -0,0 +1,121 @@
+package chroma_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+var _ = Describe("Health monitor", func() {
+	var server *fakeChroma
+	var client chroma.Chroma
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+		var err error
+		client, err = chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("tracks version, latency and clock skew", func() {
+		monitor := chroma.NewHealthMonitor(client)
+		Expect(monitor.Healthy()).To(BeFalse())
+
+		status := monitor.Check()
+		Expect(status.Healthy).To(BeTrue())
+		Expect(status.Err).ToNot(HaveOccurred())
+		Expect(status.Version).To(Equal("0.4.14"))
+		Expect(status.Latency).To(BeNumerically(">", 0))
+		// the fake server's clock is stuck in november 2023
+		Expect(status.ClockSkew).To(BeNumerically("~",
+			time.Unix(0, 1700000000000000000).Sub(time.Now()), time.Second))
+		Expect(monitor.Healthy()).To(BeTrue())
+
+		monitor = chroma.NewHealthMonitor(client, chroma.WithMaxClockSkew(time.Hour))
+		status = monitor.Check()
+		Expect(status.Healthy).To(BeFalse())
+		Expect(status.Err).To(MatchError(ContainSubstring("server clock is off")))
+	})
+
+	It("times out checks of servers that stop answering", func() {
+		release := make(chan struct{})
+		hanging := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			<-release
+		}))
+		defer hanging.Close()
+		defer close(release)
+		client, err := chroma.NewClient(hanging.URL)
+		Expect(err).ToNot(HaveOccurred())
+
+		monitor := chroma.NewHealthMonitor(client, chroma.WithCheckTimeout(20*time.Millisecond))
+		status := monitor.Check()
+		Expect(status.Healthy).To(BeFalse())
+		Expect(status.Err).To(MatchError(context.DeadlineExceeded))
+
+		// stopping doesn't wait for the running check
+		monitor = chroma.NewHealthMonitor(client, chroma.WithCheckTimeout(time.Hour))
+		monitor.Start()
+		time.Sleep(10 * time.Millisecond)
+		stopped := make(chan struct{})
+		go func() {
+			monitor.Stop()
+			close(stopped)
+		}()
+		Eventually(stopped).Should(BeClosed())
+	})
+
+	It("notifies subscribers when the server turns unhealthy", func() {
+		monitor := chroma.NewHealthMonitor(client, chroma.WithInterval(10*time.Millisecond),
+			chroma.WithUnhealthyAfter(2))
+		changes, unsubscribe := monitor.Subscribe()
+		defer unsubscribe()
+		monitor.Start()
+		defer monitor.Stop()
+
+		Eventually(changes).Should(Receive(HaveField("Healthy", true)))
+		server.SetFailing(true)
+		var status chroma.HealthStatus
+		Eventually(changes).Should(Receive(&status))
+		Expect(status.Healthy).To(BeFalse())
+		Expect(status.ConsecutiveFailures).To(Equal(2))
+		Expect(monitor.Healthy()).To(BeFalse())
+
+		server.SetFailing(false)
+		Eventually(changes).Should(Receive(HaveField("Healthy", true)))
+	})
+
+	It("serves readiness probes", func() {
+		monitor := chroma.NewHealthMonitor(client)
+		probe := func() (int, map[string]any) {
+			rec := httptest.NewRecorder()
+			monitor.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			body := map[string]any{}
+			Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+			return rec.Code, body
+		}
+
+		code, _ := probe()
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		monitor.Check()
+		code, body := probe()
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(HaveKeyWithValue("healthy", true))
+		Expect(body).To(HaveKeyWithValue("version", "0.4.14"))
+
+		server.SetFailing(true)
+		monitor.Check()
+		code, body = probe()
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(body).To(HaveKey("error"))
+	})
+})
@@ -0,0 +1,263 @@
+package chroma
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+// ImportFormat is the format read by Collection.Import
+type ImportFormat int
+
+const (
+	// ImportJSONL reads JSON Lines as written by Collection.Export, gzipped or not
+	ImportJSONL ImportFormat = iota
+	// ImportCSV reads CSV with a header row, mapped to documents by ImportOptions.CSV
+	ImportCSV
+)
+
+// CSVColumns maps CSV columns to document fields by their name in the header row
+type CSVColumns struct {
+	ID      string // defaults to "id"
+	Content string // defaults to "content"
+	// Metadata lists the columns stored as string metadata values
+	Metadata []string
+	// Embedding is an optional column holding embeddings as json arrays
+	Embedding string
+}
+
+// ImportOptions configures Collection.Import
+type ImportOptions struct {
+	Format ImportFormat
+	CSV    CSVColumns
+	// BatchSize is the number of documents upserted per request. Defaults to 100
+	BatchSize int
+	// Embedder embeds documents without embeddings. Defaults to the collection's embedder
+	Embedder embeddings.Embedder
+	// Offset skips as many records, to resume a failed import from ImportError.Offset
+	Offset int
+	// Checkpoint is called after every batch written with the number of records imported so far,
+	// to be persisted and given as Offset when resuming
+	Checkpoint func(offset int)
+}
+
+// ImportError reports a failed import. Records before Offset were imported
+type ImportError struct {
+	Offset int
+	Err    error
+}
+
+func (i *ImportError) Error() string {
+	return fmt.Sprintf("import failed after %d records: %v", i.Offset, i.Err)
+}
+
+func (i *ImportError) Unwrap() error {
+	return i.Err
+}
+
+// Import reads documents from r and upserts them into the collection in batches, embedding the
+// ones without embeddings. It returns the number of records imported including the skipped ones,
+// and an *ImportError telling where to resume when it fails
+func (c Collection) Import(ctx context.Context, r io.Reader, opts ImportOptions) (int, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	var records recordReader
+	var err error
+	switch opts.Format {
+	case ImportJSONL:
+		records, err = newJSONLReader(r)
+	case ImportCSV:
+		records, err = newCSVReader(r, opts.CSV)
+	default:
+		err = fmt.Errorf("unknown import format %d", opts.Format)
+	}
+	if err != nil {
+		return opts.Offset, &ImportError{Offset: opts.Offset, Err: err}
+	}
+
+	offset := 0
+	var batch []Document
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := c.UpsertContext(ctx, batch, opts.Embedder); err != nil {
+			return err
+		}
+		offset += len(batch)
+		batch = batch[:0]
+		if opts.Checkpoint != nil {
+			opts.Checkpoint(offset)
+		}
+		return nil
+	}
+	for {
+		doc, err := records.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return offset, &ImportError{Offset: offset, Err: err}
+		}
+		if offset < opts.Offset {
+			offset++
+			continue
+		}
+		batch = append(batch, doc)
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return offset, &ImportError{Offset: offset, Err: err}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return offset, &ImportError{Offset: offset, Err: err}
+	}
+	return offset, nil
+}
+
+// recordReader reads the documents of an import one at a time, returning io.EOF after the last one
+type recordReader interface {
+	next() (Document, error)
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLReader(r io.Reader) (*jsonlReader, error) {
+	br := bufio.NewReader(r)
+	// exports may be gzipped
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	} else {
+		r = br
+	}
+	scanner := bufio.NewScanner(r)
+	// lines hold whole embeddings
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &jsonlReader{scanner: scanner}, nil
+}
+
+func (j *jsonlReader) next() (Document, error) {
+	for j.scanner.Scan() {
+		j.line++
+		line := bytes.TrimSpace(j.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record struct {
+			ExportRecord
+			Collection *ExportedCollection `json:"collection"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return Document{}, fmt.Errorf("line %d: %w", j.line, err)
+		}
+		if record.Collection != nil {
+			// the header of an export
+			continue
+		}
+		if record.ID == "" {
+			return Document{}, fmt.Errorf("line %d: record without id", j.line)
+		}
+		return Document{
+			ID: record.ID, Content: record.Content, Metadata: record.Metadata, Embeddings: record.Embedding,
+		}, nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return Document{}, err
+	}
+	return Document{}, io.EOF
+}
+
+type csvReader struct {
+	reader    *csv.Reader
+	columns   CSVColumns
+	id        int
+	content   int
+	embedding int
+	metadata  []int
+	line      int
+}
+
+func newCSVReader(r io.Reader, columns CSVColumns) (*csvReader, error) {
+	if columns.ID == "" {
+		columns.ID = "id"
+	}
+	if columns.Content == "" {
+		columns.Content = "content"
+	}
+	c := &csvReader{reader: csv.NewReader(r), columns: columns, embedding: -1, line: 1}
+	header, err := c.reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[name] = i
+	}
+	column := func(name string) (int, error) {
+		i, ok := index[name]
+		if !ok {
+			return -1, fmt.Errorf("csv header has no column %q", name)
+		}
+		return i, nil
+	}
+	if c.id, err = column(columns.ID); err != nil {
+		return nil, err
+	}
+	if c.content, err = column(columns.Content); err != nil {
+		return nil, err
+	}
+	if columns.Embedding != "" {
+		if c.embedding, err = column(columns.Embedding); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range columns.Metadata {
+		i, err := column(name)
+		if err != nil {
+			return nil, err
+		}
+		c.metadata = append(c.metadata, i)
+	}
+	return c, nil
+}
+
+func (c *csvReader) next() (Document, error) {
+	row, err := c.reader.Read()
+	if err != nil {
+		return Document{}, err
+	}
+	c.line++
+	doc := Document{ID: row[c.id], Content: row[c.content]}
+	if doc.ID == "" {
+		return Document{}, fmt.Errorf("row %d: empty id", c.line)
+	}
+	if c.embedding >= 0 && row[c.embedding] != "" {
+		if err := json.Unmarshal([]byte(row[c.embedding]), &doc.Embeddings); err != nil {
+			return Document{}, fmt.Errorf("row %d: embedding: %w", c.line, err)
+		}
+	}
+	if len(c.metadata) > 0 {
+		doc.Metadata = map[string]any{}
+		for i, column := range c.metadata {
+			doc.Metadata[c.columns.Metadata[i]] = row[column]
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,102 @@
+package chroma_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+var _ = Describe("Import", func() {
+	var server *fakeChroma
+	var client chroma.Chroma
+	var collection chroma.Collection
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+		var err error
+		client, err = chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		collection, err = client.CreateCollection("imported", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("imports exports", func() {
+		source, err := client.CreateCollection("exported", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(source.Add([]chroma.Document{
+			{ID: "1", Content: "a", Metadata: map[string]any{"k": "v"}, Embeddings: []float32{1, 2}},
+			{ID: "2", Content: "b", Embeddings: []float32{3, 4}},
+		}, nil)).To(Succeed())
+		buf := &bytes.Buffer{}
+		_, err = source.Export(context.Background(), buf, chroma.ExportOptions{Gzip: true})
+		Expect(err).ToNot(HaveOccurred())
+
+		n, err := collection.Import(context.Background(), buf, chroma.ImportOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(2))
+		docs, err := collection.Get(nil, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(docs).To(HaveLen(2))
+		Expect(docs[0].Metadata).To(Equal(map[string]any{"k": "v"}))
+		Expect(server.Requests()).To(ContainElement("POST /collections/" + collection.ID + "/upsert"))
+	})
+
+	It("imports csv with mapped columns and embeds rows without vectors", func() {
+		csv := "key,text,lang,vector\n" +
+			"1,hello,en,\"[1,2,3]\"\n" +
+			"2,bonjour,fr,\n"
+		embedder := &recordingEmbedder{}
+		n, err := collection.Import(context.Background(), strings.NewReader(csv), chroma.ImportOptions{
+			Format:   chroma.ImportCSV,
+			CSV:      chroma.CSVColumns{ID: "key", Content: "text", Metadata: []string{"lang"}, Embedding: "vector"},
+			Embedder: embedder,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(2))
+		Expect(embedder.batches).To(Equal([][]string{{"bonjour"}}))
+
+		docs, err := collection.Get([]string{"1"}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(docs[0].Content).To(Equal("hello"))
+		Expect(docs[0].Metadata).To(Equal(map[string]any{"lang": "en"}))
+
+		_, err = collection.Import(context.Background(), strings.NewReader(csv), chroma.ImportOptions{
+			Format: chroma.ImportCSV, CSV: chroma.CSVColumns{ID: "missing"},
+		})
+		Expect(err).To(MatchError(ContainSubstring(`csv header has no column "missing"`)))
+	})
+
+	It("resumes from a checkpoint after a failure", func() {
+		export := &bytes.Buffer{}
+		_, err := seedCollection(client, "exported", 5).Export(context.Background(), export, chroma.ExportOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		var checkpoints []int
+		opts := chroma.ImportOptions{BatchSize: 2, Checkpoint: func(offset int) {
+			checkpoints = append(checkpoints, offset)
+			server.SetFailing(true)
+		}}
+		_, err = collection.Import(context.Background(), bytes.NewReader(export.Bytes()), opts)
+		var importErr *chroma.ImportError
+		Expect(errors.As(err, &importErr)).To(BeTrue())
+		Expect(importErr.Offset).To(Equal(2))
+		Expect(checkpoints).To(Equal([]int{2}))
+
+		server.SetFailing(false)
+		opts.Offset = importErr.Offset
+		opts.Checkpoint = func(offset int) { checkpoints = append(checkpoints, offset) }
+		n, err := collection.Import(context.Background(), bytes.NewReader(export.Bytes()), opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(5))
+		Expect(checkpoints).To(Equal([]int{2, 4, 5}))
+		Expect(collection.Count()).To(Equal(5))
+	})
+})
@@ -0,0 +1,17 @@
+// Package logging provides the logger used by the client and embedders when none is configured
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Discard is a logger dropping every record
+var Discard = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
@@ -0,0 +1,104 @@
+// Package ratelimit provides the token bucket and semaphore behind the client and embedder limits
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket refilled at a constant rate up to its burst size. It starts full
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a bucket refilled with rate tokens per second, holding at most burst tokens
+func NewBucket(rate float64, burst int) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait blocks until n tokens are available and takes them, or returns the context's error when
+// it is done first. Requests for more tokens than the burst size wait for a full bucket
+func (b *Bucket) Wait(ctx context.Context, n float64) error {
+	n = min(n, b.burst)
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= n {
+			b.tokens -= n
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Semaphore caps the number of concurrent holders
+type Semaphore chan struct{}
+
+// NewSemaphore returns a semaphore allowing size concurrent holders
+func NewSemaphore(size int) Semaphore {
+	return make(Semaphore, size)
+}
+
+// Acquire blocks until the semaphore can be held, or returns the context's error when it is done first
+func (s Semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s Semaphore) Release() {
+	<-s
+}
+
+// Limiter combines an optional Bucket and an optional Semaphore
+type Limiter struct {
+	Bucket    *Bucket
+	Semaphore Semaphore
+}
+
+// Acquire takes n tokens from the bucket and holds the semaphore. The returned release func must
+// be called once the limited work is done
+func (l Limiter) Acquire(ctx context.Context, n float64) (release func(), err error) {
+	if l.Semaphore != nil {
+		if err := l.Semaphore.Acquire(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if l.Bucket != nil {
+		if err := l.Bucket.Wait(ctx, n); err != nil {
+			if l.Semaphore != nil {
+				l.Semaphore.Release()
+			}
+			return nil, err
+		}
+	}
+	var once sync.Once
+	return func() {
+		if l.Semaphore != nil {
+			once.Do(l.Semaphore.Release)
+		}
+	}, nil
+}
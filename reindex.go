@@ -0,0 +1,114 @@
+package chroma
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+// ReindexOptions configures Client.Reindex
+type ReindexOptions struct {
+	// Embedder embeds the documents of the new collection
+	Embedder embeddings.Embedder
+	// CollectionOptions are used to create the new collection, like WithRegisteredEmbedder to record
+	// the new embedder
+	CollectionOptions []CollectionOption
+	// Name is the name of the new collection. Defaults to the alias followed by the unix time
+	Name string
+	// BatchSize is the number of documents fetched and written per request. Defaults to 100
+	BatchSize int
+	// Progress is called after every batch written with the number of documents copied so far and
+	// the number of documents the collection held when the reindex started
+	Progress func(copied, total int)
+	// DeleteSource deletes the previous collection once the alias points to the new one
+	DeleteSource bool
+}
+
+// CountMismatchError reports a reindexed collection holding a different number of documents than
+// its source, usually because the source was written to during the reindex
+type CountMismatchError struct {
+	Source int
+	Target int
+}
+
+func (c *CountMismatchError) Error() string {
+	return fmt.Sprintf("reindexed collection holds %d documents, its source %d", c.Target, c.Source)
+}
+
+// Reindex re-embeds the collection aliased by alias, or called alias when there is no such alias,
+// into a new collection with opts.Embedder. Once the document counts of both collections match,
+// alias is pointed to the new collection, so clients created WithAliases get it from
+// GetCollection(alias) without ever seeing a partial collection. The new collection is deleted
+// when the reindex fails
+func (c *Client) Reindex(ctx context.Context, alias string, opts ReindexOptions) (Collection, error) {
+	if opts.Embedder == nil {
+		return Collection{}, fmt.Errorf("reindex needs an embedder")
+	}
+	if opts.Name == "" {
+		opts.Name = fmt.Sprintf("%s-%d", alias, time.Now().Unix())
+	}
+	source, ok, err := c.lookupAlias(ctx, alias)
+	if err != nil {
+		return Collection{}, err
+	}
+	if !ok {
+		source = alias
+	}
+	src, err := c.getCollection(source, nil)
+	if err != nil {
+		return Collection{}, err
+	}
+	total, err := src.CountContext(ctx)
+	if err != nil {
+		return Collection{}, err
+	}
+
+	copyOpts := CopyOptions{
+		Name:              opts.Name,
+		BatchSize:         opts.BatchSize,
+		Embedder:          opts.Embedder,
+		CollectionOptions: opts.CollectionOptions,
+	}
+	if opts.Progress != nil {
+		copyOpts.Progress = func(copied int) { opts.Progress(copied, total) }
+	}
+	target, _, err := CopyCollection(ctx, src, c, copyOpts)
+	if err == nil {
+		err = verifyCount(ctx, src, target)
+	}
+	if err == nil {
+		err = c.setAlias(ctx, alias, target.Name)
+	}
+	if err != nil {
+		if target.ID != "" {
+			// best effort, the error that made the reindex fail matters more
+			c.DeleteCollection(target.Name)
+		}
+		return Collection{}, fmt.Errorf("reindexing %s: %w", alias, err)
+	}
+
+	if opts.DeleteSource {
+		if err := c.DeleteCollection(source); err != nil {
+			return target, fmt.Errorf("deleting reindexed collection %s: %w", source, err)
+		}
+	}
+	return target, nil
+}
+
+// verifyCount checks that target holds as many documents as src
+func verifyCount(ctx context.Context, src, target Collection) error {
+	sourceCount, err := src.CountContext(ctx)
+	if err != nil {
+		return err
+	}
+	targetCount, err := target.CountContext(ctx)
+	if err != nil {
+		return err
+	}
+	if sourceCount != targetCount {
+		return &CountMismatchError{Source: sourceCount, Target: targetCount}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package chroma_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+var _ = Describe("Reindex", func() {
+	var server *fakeChroma
+	var client *chroma.Client
+	var source chroma.Collection
+	embedder := embeddings.NewHashEmbedder(4)
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+		c, err := chroma.NewClient(server.URL, chroma.WithAliases())
+		Expect(err).ToNot(HaveOccurred())
+		client = c.(*chroma.Client)
+		source = seedCollection(client, "docs", 5)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("re-embeds into a new collection and points the alias to it", func() {
+		var progress [][2]int
+		reindexed, err := client.Reindex(context.Background(), "docs", chroma.ReindexOptions{
+			Embedder:     embedder,
+			Name:         "docs-v2",
+			BatchSize:    2,
+			Progress:     func(copied, total int) { progress = append(progress, [2]int{copied, total}) },
+			DeleteSource: true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reindexed.Name).To(Equal("docs-v2"))
+		Expect(progress).To(Equal([][2]int{{2, 5}, {4, 5}, {5, 5}}))
+		Expect(server.byName("docs")).To(BeNil())
+
+		collection, err := client.GetCollection("docs")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Name).To(Equal("docs-v2"))
+		Expect(collection.DistanceFn).To(Equal("cosine"))
+		Expect(collection.Count()).To(Equal(5))
+		expected, err := embedder.EmbedQuery(context.Background(), "doc 0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.byName("docs-v2").docs[0].Embedding).To(Equal(expected))
+
+		// a second reindex starts from the aliased collection
+		_, err = client.Reindex(context.Background(), "docs", chroma.ReindexOptions{Embedder: embedder, Name: "docs-v3"})
+		Expect(err).ToNot(HaveOccurred())
+		collection, err = client.GetCollection("docs")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Name).To(Equal("docs-v3"))
+		Expect(server.byName("docs-v2")).ToNot(BeNil())
+	})
+
+	It("leaves the alias unchanged when the counts don't match", func() {
+		_, err := client.Reindex(context.Background(), "docs", chroma.ReindexOptions{
+			Embedder: embedder,
+			Name:     "docs-v2",
+			Progress: func(copied, total int) {
+				// written while the reindex runs
+				Expect(source.Add([]chroma.Document{{ID: "late", Embeddings: []float32{1, 1}}}, nil)).To(Succeed())
+			},
+		})
+		var mismatch *chroma.CountMismatchError
+		Expect(errors.As(err, &mismatch)).To(BeTrue())
+		Expect(mismatch).To(Equal(&chroma.CountMismatchError{Source: 6, Target: 5}))
+		Expect(server.byName("docs-v2")).To(BeNil())
+
+		collection, err := client.GetCollection("docs")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Name).To(Equal("docs"))
+	})
+})
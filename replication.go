@@ -0,0 +1,389 @@
+package chroma
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ReplicatedClient writes to several chroma servers and reads from a preferred one, to keep
+// clusters in sync during migrations. Collections it returns fan out Add, Upsert and Delete to
+// every backend
+type ReplicatedClient struct {
+	backends     []*Client
+	preferred    int
+	quorum       int
+	onDivergence func(err *DivergenceError)
+
+	mu sync.Mutex
+	// replicas maps the id of every collection returned to its replicas
+	replicas map[string]replicaSet
+}
+
+// replicaSet is a collection on every backend
+type replicaSet struct {
+	name string
+	ids  []string // "" where the collection is missing
+}
+
+// ReplicationOption configures a ReplicatedClient
+type ReplicationOption func(*ReplicatedClient)
+
+// WithPreferredBackend sets the index of the backend serving reads. Defaults to the first one
+func WithPreferredBackend(backend int) ReplicationOption {
+	return func(r *ReplicatedClient) {
+		r.preferred = backend
+	}
+}
+
+// WithWriteQuorum sets how many backends have to accept a write for it to succeed. Defaults to all of them
+func WithWriteQuorum(quorum int) ReplicationOption {
+	return func(r *ReplicatedClient) {
+		r.quorum = quorum
+	}
+}
+
+// WithDivergenceHandler calls handler when some backends failed a write that still reached the quorum,
+// or failed to return a collection the preferred backend returned
+func WithDivergenceHandler(handler func(err *DivergenceError)) ReplicationOption {
+	return func(r *ReplicatedClient) {
+		r.onDivergence = handler
+	}
+}
+
+// BackendError is the error of one backend of a ReplicatedClient
+type BackendError struct {
+	Backend int
+	URL     string
+	Err     error
+}
+
+// DivergenceError reports the backends that failed a replicated call
+type DivergenceError struct {
+	Operation  Operation
+	Collection string // name of the collection, empty for client level operations
+	Succeeded  int
+	Failed     []BackendError
+}
+
+func (d *DivergenceError) Error() string {
+	failures := make([]string, len(d.Failed))
+	for i, f := range d.Failed {
+		failures[i] = fmt.Sprintf("backend %d (%s): %v", f.Backend, f.URL, f.Err)
+	}
+	target := ""
+	if d.Collection != "" {
+		target = " of collection " + d.Collection
+	}
+	return fmt.Sprintf("%s%s failed on %d of %d backends: %s", d.Operation, target, len(d.Failed),
+		d.Succeeded+len(d.Failed), strings.Join(failures, "; "))
+}
+
+func (d *DivergenceError) Unwrap() []error {
+	errs := make([]error, len(d.Failed))
+	for i, f := range d.Failed {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// NewReplicatedClient returns a client replicating writes to backends, which have to be created with NewClient
+func NewReplicatedClient(backends []Chroma, opts ...ReplicationOption) (*ReplicatedClient, error) {
+	r := &ReplicatedClient{quorum: len(backends), replicas: map[string]replicaSet{}}
+	for i, backend := range backends {
+		client, ok := backend.(*Client)
+		if !ok {
+			return nil, fmt.Errorf("backend %d is a %T, expected a client created with NewClient", i, backend)
+		}
+		r.backends = append(r.backends, client)
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if len(r.backends) == 0 {
+		return nil, errors.New("no backends given")
+	}
+	if r.preferred < 0 || r.preferred >= len(r.backends) {
+		return nil, fmt.Errorf("preferred backend %d out of range", r.preferred)
+	}
+	if r.quorum < 1 || r.quorum > len(r.backends) {
+		return nil, fmt.Errorf("write quorum %d out of range for %d backends", r.quorum, len(r.backends))
+	}
+	return r, nil
+}
+
+var _ Chroma = (*ReplicatedClient)(nil)
+
+func (r *ReplicatedClient) BaseUrl() string {
+	return r.backends[r.preferred].BaseUrl()
+}
+
+func (r *ReplicatedClient) Heartbeat() (int, error) {
+	return r.backends[r.preferred].Heartbeat()
+}
+
+func (r *ReplicatedClient) GetVersion() (string, error) {
+	return r.backends[r.preferred].GetVersion()
+}
+
+func (r *ReplicatedClient) Reset() (bool, error) {
+	results := make([]bool, len(r.backends))
+	err := r.fanOut(OpReset, "", func(i int, backend *Client) error {
+		var err error
+		results[i], err = backend.Reset()
+		return err
+	})
+	return results[r.preferred], err
+}
+
+func (r *ReplicatedClient) ListCollections() ([]Collection, error) {
+	lists := make([][]Collection, len(r.backends))
+	errs := make([]error, len(r.backends))
+	r.each(func(i int, backend *Client) {
+		lists[i], errs[i] = backend.ListCollections()
+	})
+	if errs[r.preferred] != nil {
+		return nil, errs[r.preferred]
+	}
+	collections := lists[r.preferred]
+	for i := range collections {
+		replicas := make([]Collection, len(r.backends))
+		for b, list := range lists {
+			for _, c := range list {
+				if c.Name == collections[i].Name {
+					replicas[b] = c
+				}
+			}
+		}
+		r.bind(&collections[i], replicas)
+	}
+	return collections, nil
+}
+
+func (r *ReplicatedClient) GetOrCreateCollection(name string, distanceFn string, metadata map[string]any,
+	opts ...CollectionOption) (Collection, error) {
+	return r.replicate(OpCreateCollection, name, func(backend *Client) (Collection, error) {
+		return backend.GetOrCreateCollection(name, distanceFn, copyMetadata(metadata), opts...)
+	})
+}
+
+func (r *ReplicatedClient) CreateCollection(name string, distanceFn string, metadata map[string]any,
+	opts ...CollectionOption) (Collection, error) {
+	return r.replicate(OpCreateCollection, name, func(backend *Client) (Collection, error) {
+		return backend.CreateCollection(name, distanceFn, copyMetadata(metadata), opts...)
+	})
+}
+
+func (r *ReplicatedClient) DeleteCollection(name string) error {
+	return r.fanOut(OpDeleteCollection, name, func(_ int, backend *Client) error {
+		return backend.DeleteCollection(name)
+	})
+}
+
+// GetCollection gets the collection from every backend. It fails when the preferred backend does
+func (r *ReplicatedClient) GetCollection(name string, opts ...CollectionOption) (Collection, error) {
+	replicas := make([]Collection, len(r.backends))
+	errs := make([]error, len(r.backends))
+	r.each(func(i int, backend *Client) {
+		replicas[i], errs[i] = backend.GetCollection(name, opts...)
+	})
+	if errs[r.preferred] != nil {
+		return Collection{}, errs[r.preferred]
+	}
+	if divergence := r.divergence(OpGetCollection, name, errs); divergence != nil {
+		r.diverged(divergence)
+	}
+	collection := replicas[r.preferred]
+	r.bind(&collection, replicas)
+	return collection, nil
+}
+
+// replicate creates a collection on every backend, failing when fewer than the quorum did
+func (r *ReplicatedClient) replicate(op Operation, name string,
+	create func(backend *Client) (Collection, error)) (Collection, error) {
+	replicas := make([]Collection, len(r.backends))
+	err := r.fanOut(op, name, func(i int, backend *Client) error {
+		collection, err := create(backend)
+		if err == nil {
+			replicas[i] = collection
+		}
+		return err
+	})
+	if err != nil {
+		return Collection{}, err
+	}
+	collection := replicas[r.preferred]
+	if collection.ID == "" {
+		// the preferred backend failed but the quorum was reached
+		for _, replica := range replicas {
+			if replica.ID != "" {
+				collection = replica
+				break
+			}
+		}
+	}
+	r.bind(&collection, replicas)
+	return collection, nil
+}
+
+// bind routes the requests of collection through the replicated client
+func (r *ReplicatedClient) bind(collection *Collection, replicas []Collection) {
+	ids := make([]string, len(replicas))
+	for i, replica := range replicas {
+		ids[i] = replica.ID
+	}
+	r.mu.Lock()
+	r.replicas[collection.ID] = replicaSet{name: collection.Name, ids: ids}
+	r.mu.Unlock()
+	collection.server = r
+}
+
+// each calls f for every backend concurrently and waits for all calls to return
+func (r *ReplicatedClient) each(f func(i int, backend *Client)) {
+	var wg sync.WaitGroup
+	for i, backend := range r.backends {
+		wg.Add(1)
+		go func(i int, backend *Client) {
+			defer wg.Done()
+			f(i, backend)
+		}(i, backend)
+	}
+	wg.Wait()
+}
+
+// fanOut calls f for every backend, returning a DivergenceError when fewer than the quorum succeeded
+func (r *ReplicatedClient) fanOut(op Operation, collection string, f func(i int, backend *Client) error) error {
+	errs := make([]error, len(r.backends))
+	r.each(func(i int, backend *Client) {
+		errs[i] = f(i, backend)
+	})
+	return r.checkQuorum(r.divergence(op, collection, errs))
+}
+
+func (r *ReplicatedClient) divergence(op Operation, collection string, errs []error) *DivergenceError {
+	d := &DivergenceError{Operation: op, Collection: collection}
+	for i, err := range errs {
+		if err == nil {
+			d.Succeeded++
+			continue
+		}
+		d.Failed = append(d.Failed, BackendError{Backend: i, URL: r.backends[i].BaseUrl(), Err: err})
+	}
+	if len(d.Failed) == 0 {
+		return nil
+	}
+	return d
+}
+
+// checkQuorum returns d if it failed the quorum, and reports it to the divergence handler otherwise
+func (r *ReplicatedClient) checkQuorum(d *DivergenceError) error {
+	if d == nil {
+		return nil
+	}
+	if d.Succeeded < r.quorum {
+		return d
+	}
+	r.diverged(d)
+	return nil
+}
+
+func (r *ReplicatedClient) diverged(d *DivergenceError) {
+	if r.onDivergence != nil {
+		r.onDivergence(d)
+	}
+}
+
+// send serves the requests of the collections returned by the client: reads go to the preferred
+// backend, writes to every backend holding the collection
+func (r *ReplicatedClient) send(ctx context.Context, method, path string, body io.Reader,
+	info RequestInfo) (*http.Response, error) {
+	r.mu.Lock()
+	set, ok := r.replicas[info.CollectionID]
+	r.mu.Unlock()
+	ids := set.ids
+	if !ok {
+		return r.backends[r.preferred].send(ctx, method, path, body, info)
+	}
+	rest := strings.TrimPrefix(path, "/collections/"+info.CollectionID)
+	if info.Operation.Class() == ReadOperations {
+		if ids[r.preferred] == "" {
+			return nil, fmt.Errorf("collection %s is missing on the preferred backend", set.name)
+		}
+		return r.backends[r.preferred].send(ctx, method, "/collections/"+ids[r.preferred]+rest, body, info)
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+	responses := make([]*http.Response, len(r.backends))
+	errs := make([]error, len(r.backends))
+	r.each(func(i int, backend *Client) {
+		if ids[i] == "" {
+			errs[i] = errors.New("collection is missing on this backend")
+			return
+		}
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		responses[i], errs[i] = backend.send(ctx, method, "/collections/"+ids[i]+rest, body, info)
+		if errs[i] == nil && responses[i].StatusCode >= http.StatusBadRequest {
+			// keep the body, the caller reads chroma's error from the chosen response
+			bodyBuf, _ := io.ReadAll(responses[i].Body)
+			responses[i].Body.Close()
+			responses[i].Body = io.NopCloser(bytes.NewReader(bodyBuf))
+			errs[i] = fmt.Errorf("%w: %s", &StatusError{StatusCode: responses[i].StatusCode}, bodyBuf)
+		}
+	})
+
+	// answer with the preferred backend's response when it succeeded, the first success otherwise
+	chosen := -1
+	for _, i := range append([]int{r.preferred}, r.order()...) {
+		if errs[i] == nil {
+			chosen = i
+			break
+		}
+	}
+	for i, resp := range responses {
+		if resp != nil && i != chosen {
+			resp.Body.Close()
+		}
+	}
+	if err := r.checkQuorum(r.divergence(info.Operation, set.name, errs)); err != nil {
+		if chosen >= 0 {
+			responses[chosen].Body.Close()
+		}
+		return nil, err
+	}
+	return responses[chosen], nil
+}
+
+// order returns the indexes of all backends
+func (r *ReplicatedClient) order() []int {
+	order := make([]int, len(r.backends))
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// copyMetadata copies metadata so concurrent creates on several backends don't share a map
+func copyMetadata(metadata map[string]any) map[string]any {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]any, len(metadata))
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	return copied
+}
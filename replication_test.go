@@ -0,0 +1,123 @@
+package chroma_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+var _ = Describe("Replicated client", func() {
+	var source, target *fakeChroma
+	var backends []chroma.Chroma
+
+	BeforeEach(func() {
+		source, target = newFakeChroma(), newFakeChroma()
+		backends = nil
+		for _, server := range []*fakeChroma{source, target} {
+			client, err := chroma.NewClient(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+			backends = append(backends, client)
+		}
+	})
+
+	AfterEach(func() {
+		source.Close()
+		target.Close()
+	})
+
+	It("writes to every backend and reads from the preferred one", func() {
+		client, err := chroma.NewReplicatedClient(backends, chroma.WithPreferredBackend(1))
+		Expect(err).ToNot(HaveOccurred())
+		// the collection gets a different id on the old cluster
+		_, err = backends[0].CreateCollection("other", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		collection, err := client.CreateCollection("migrated", "cosine", map[string]any{"team": "search"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Metadata).To(HaveKeyWithValue("team", "search"))
+
+		Expect(collection.Add([]chroma.Document{{ID: "1", Content: "a"}, {ID: "2", Content: "b"}},
+			testEmbedder{})).To(Succeed())
+		Expect(collection.Upsert([]chroma.Document{{ID: "2", Content: "bb"}, {ID: "3", Content: "c"}},
+			testEmbedder{})).To(Succeed())
+		Expect(collection.Delete([]string{"1"}, nil, nil)).To(Succeed())
+		sourceID, targetID := source.byName("migrated").ID, target.byName("migrated").ID
+		Expect(sourceID).ToNot(Equal(targetID))
+		Expect(source.Requests()).To(ContainElements("POST /collections/"+sourceID+"/add",
+			"POST /collections/"+sourceID+"/upsert", "POST /collections/"+sourceID+"/delete"))
+		Expect(target.Requests()).To(ContainElements("POST /collections/"+targetID+"/add",
+			"POST /collections/"+targetID+"/upsert", "POST /collections/"+targetID+"/delete"))
+
+		oldSent := len(source.Requests())
+		docs, err := collection.Get(nil, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(docs).To(HaveLen(2))
+		Expect(docs[0].ID).To(Equal("2"))
+		Expect(docs[0].Content).To(Equal("bb"))
+		Expect(collection.Count()).To(Equal(2))
+		Expect(source.Requests()).To(HaveLen(oldSent))
+
+		for _, backend := range backends {
+			replica, err := backend.GetCollection("migrated")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(replica.Count()).To(Equal(2))
+		}
+
+		Expect(client.DeleteCollection("migrated")).To(Succeed())
+		for _, backend := range backends {
+			_, err := backend.GetCollection("migrated")
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("reports backends diverging from a write", func() {
+		var divergences []*chroma.DivergenceError
+		client, err := chroma.NewReplicatedClient(backends, chroma.WithWriteQuorum(1),
+			chroma.WithDivergenceHandler(func(err *chroma.DivergenceError) {
+				divergences = append(divergences, err)
+			}))
+		Expect(err).ToNot(HaveOccurred())
+		collection, err := client.GetOrCreateCollection("migrated", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		target.SetFailing(true)
+		Expect(collection.Add([]chroma.Document{{ID: "1", Content: "a"}}, testEmbedder{})).To(Succeed())
+		Expect(divergences).To(HaveLen(1))
+		Expect(divergences[0].Operation).To(Equal(chroma.OpAdd))
+		Expect(divergences[0].Collection).To(Equal("migrated"))
+		Expect(divergences[0].Succeeded).To(Equal(1))
+		Expect(divergences[0].Failed).To(HaveLen(1))
+		Expect(divergences[0].Failed[0].Backend).To(Equal(1))
+		var statusErr *chroma.StatusError
+		Expect(errors.As(divergences[0], &statusErr)).To(BeTrue())
+		Expect(statusErr.StatusCode).To(Equal(500))
+	})
+
+	It("fails writes missing the quorum", func() {
+		client, err := chroma.NewReplicatedClient(backends)
+		Expect(err).ToNot(HaveOccurred())
+		collection, err := client.CreateCollection("migrated", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		source.SetFailing(true)
+		err = collection.Add([]chroma.Document{{ID: "1", Content: "a"}}, testEmbedder{})
+		var divergence *chroma.DivergenceError
+		Expect(errors.As(err, &divergence)).To(BeTrue())
+		Expect(divergence.Failed[0].Backend).To(Equal(0))
+		Expect(err).To(MatchError(ContainSubstring("add of collection migrated failed on 1 of 2 backends")))
+
+		_, err = client.CreateCollection("other", "l2", nil)
+		Expect(errors.As(err, &divergence)).To(BeTrue())
+		Expect(divergence.Operation).To(Equal(chroma.OpCreateCollection))
+	})
+
+	It("validates its configuration", func() {
+		_, err := chroma.NewReplicatedClient(nil)
+		Expect(err).To(HaveOccurred())
+		_, err = chroma.NewReplicatedClient(backends, chroma.WithWriteQuorum(3))
+		Expect(err).To(HaveOccurred())
+		_, err = chroma.NewReplicatedClient(backends, chroma.WithPreferredBackend(2))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,207 @@
+package chroma
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Operation names a client or collection call, used in logs and instrumentation
+type Operation string
+
+const (
+	OpHeartbeat        Operation = "heartbeat"
+	OpReset            Operation = "reset"
+	OpVersion          Operation = "version"
+	OpListCollections  Operation = "listCollections"
+	OpCreateCollection Operation = "createCollection"
+	OpGetCollection    Operation = "getCollection"
+	OpDeleteCollection Operation = "deleteCollection"
+	OpAdd              Operation = "add"
+	OpUpsert           Operation = "upsert"
+	OpDelete           Operation = "delete"
+	OpGet              Operation = "get"
+	OpQuery            Operation = "query"
+	OpCount            Operation = "count"
+)
+
+// OperationClass groups operations for rate limiting
+type OperationClass string
+
+const (
+	ReadOperations  OperationClass = "read"
+	WriteOperations OperationClass = "write"
+)
+
+// Class returns WriteOperations for operations changing the server's data and ReadOperations otherwise
+func (o Operation) Class() OperationClass {
+	switch o {
+	case OpReset, OpCreateCollection, OpDeleteCollection, OpAdd, OpUpsert, OpDelete:
+		return WriteOperations
+	default:
+		return ReadOperations
+	}
+}
+
+// RequestInfo describes a request to the server
+type RequestInfo struct {
+	Operation    Operation
+	CollectionID string // empty for client level operations
+	Documents    int    // number of documents sent, if any
+	Method       string
+	Path         string
+	// Header holds the headers of the outgoing request, so instrumentation can propagate trace context
+	Header http.Header
+
+	probe bool // circuit breaker probes bypass the breaker
+}
+
+// RequestResult describes the outcome of a request to the server
+type RequestResult struct {
+	StatusCode int // 0 if no response was received
+	Duration   time.Duration
+	// Err is the transport error, or a *StatusError when the server responded with an error status
+	Err error
+}
+
+// StatusError reports a response with an error status code
+type StatusError struct {
+	StatusCode int
+}
+
+func (s *StatusError) Error() string {
+	return fmt.Sprintf("chroma responded with status %d %s", s.StatusCode, http.StatusText(s.StatusCode))
+}
+
+// Instrumentation is notified around every request the client and its collections send to the server
+type Instrumentation interface {
+	// RequestStart is called before a request is sent. The returned context is used for the request
+	// and passed on to RequestEnd, so implementations can carry state like spans in it
+	RequestStart(ctx context.Context, info RequestInfo) context.Context
+	RequestEnd(ctx context.Context, info RequestInfo, result RequestResult)
+}
+
+// do sends req with the client's http client once the rate limits allow it, logging and reporting
+// the outcome
+func (c *Client) do(req *http.Request, r RequestInfo) (*http.Response, error) {
+	// clients with multiple endpoints guard whole requests in send instead
+	guarded := c.breaker != nil && !r.probe && len(c.endpoints) == 1
+	if guarded {
+		if err := c.breaker.allow(req.Context(), c.probe); err != nil {
+			return nil, err
+		}
+	}
+	release := func() {}
+	if limiter, ok := c.limiters[r.Operation.Class()]; ok {
+		var err error
+		if release, err = limiter.Acquire(req.Context(), 1); err != nil {
+			return nil, err
+		}
+	}
+
+	r.Method = req.Method
+	r.Path = req.URL.Path
+	r.Header = req.Header
+	ctx := req.Context()
+	for _, inst := range c.instrumentation {
+		ctx = inst.RequestStart(ctx, r)
+	}
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	result := RequestResult{Duration: time.Since(start), Err: err}
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+		if resp.StatusCode >= http.StatusBadRequest {
+			result.Err = &StatusError{StatusCode: resp.StatusCode}
+		}
+	}
+	c.log(ctx, r, result)
+	if guarded {
+		c.breaker.record(result)
+	}
+	for i := len(c.instrumentation) - 1; i >= 0; i-- {
+		c.instrumentation[i].RequestEnd(ctx, r, result)
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// requests are in flight until their response is read
+	resp.Body = releasingBody{resp.Body, release}
+	return resp, nil
+}
+
+// releasingBody releases a rate limiter once the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r releasingBody) Close() error {
+	r.release()
+	return r.ReadCloser.Close()
+}
+
+// probe checks the server's heartbeat for a half open circuit breaker
+func (c *Client) probe(ctx context.Context) error {
+	resp, err := c.send(ctx, http.MethodGet, "/heartbeat", nil, RequestInfo{Operation: OpHeartbeat, probe: true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+func (c *Client) log(ctx context.Context, r RequestInfo, result RequestResult) {
+	attrs := []slog.Attr{
+		slog.String("operation", string(r.Operation)),
+		slog.String("method", r.Method),
+		slog.String("path", r.Path),
+		slog.Duration("latency", result.Duration),
+	}
+	if r.CollectionID != "" {
+		attrs = append(attrs, slog.String("collectionID", r.CollectionID))
+	}
+	if r.Documents > 0 {
+		attrs = append(attrs, slog.Int("batchSize", r.Documents))
+	}
+	if result.StatusCode == 0 {
+		c.logger.LogAttrs(ctx, slog.LevelError, "chroma request failed", append(attrs, slog.Any("error", result.Err))...)
+		return
+	}
+	level := slog.LevelDebug
+	if result.Err != nil {
+		level = slog.LevelWarn
+	}
+	c.logger.LogAttrs(ctx, level, "chroma request", append(attrs, slog.Int("status", result.StatusCode))...)
+}
+
+// sender is implemented by servers that send collection requests themselves, like Client
+type sender interface {
+	send(ctx context.Context, method, path string, body io.Reader, r RequestInfo) (*http.Response, error)
+}
+
+// send sends a request for path, relative to the collection's url, through the collection's server
+// when it is a Client or with http.DefaultClient otherwise
+func (c Collection) send(ctx context.Context, method, path string, body io.Reader, r RequestInfo) (*http.Response, error) {
+	r.CollectionID = c.ID
+	if s, ok := c.server.(sender); ok {
+		return s.send(ctx, method, "/collections/"+c.ID+path, body, r)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.server.BaseUrl()+"/collections/"+c.ID+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return http.DefaultClient.Do(req)
+}
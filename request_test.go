@@ -0,0 +1,183 @@
+package chroma_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+	"github.com/urjitbhatia/gochroma/embeddings"
+)
+
+// logLines decodes every json log line written to buf
+func logLines(buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		entry := map[string]any{}
+		Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed())
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+var _ = Describe("Logging", func() {
+	var server *fakeChroma
+	var buf *bytes.Buffer
+	var handler slog.Handler
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+		buf = &bytes.Buffer{}
+		handler = slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("logs client and collection requests", func() {
+		client, err := chroma.NewClient(server.URL, chroma.WithLogger(handler))
+		Expect(err).ToNot(HaveOccurred())
+		collection, err := client.CreateCollection("logged", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collection.Add([]chroma.Document{{ID: "1", Content: "a"}, {ID: "2", Content: "b"}}, testEmbedder{})).To(Succeed())
+		_, err = client.GetCollection("unknown")
+		Expect(err).To(HaveOccurred())
+
+		lines := logLines(buf)
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0]).To(HaveKeyWithValue("operation", "createCollection"))
+		Expect(lines[0]).To(HaveKeyWithValue("method", "POST"))
+		Expect(lines[0]).To(HaveKeyWithValue("path", "/api/v1/collections"))
+		Expect(lines[0]).To(HaveKeyWithValue("status", 200.0))
+		Expect(lines[0]).To(HaveKeyWithValue("level", "DEBUG"))
+		Expect(lines[0]).To(HaveKey("latency"))
+
+		Expect(lines[1]).To(HaveKeyWithValue("operation", "add"))
+		Expect(lines[1]).To(HaveKeyWithValue("collectionID", collection.ID))
+		Expect(lines[1]).To(HaveKeyWithValue("batchSize", 2.0))
+
+		Expect(lines[2]).To(HaveKeyWithValue("operation", "getCollection"))
+		Expect(lines[2]).To(HaveKeyWithValue("level", "WARN"))
+		Expect(lines[2]).To(HaveKeyWithValue("status", 500.0))
+	})
+
+	It("logs nothing by default", func() {
+		// not even through the default logger
+		defaultLogger := slog.Default()
+		slog.SetDefault(slog.New(handler))
+		defer slog.SetDefault(defaultLogger)
+
+		client, err := chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = client.Heartbeat()
+		Expect(err).ToNot(HaveOccurred())
+		server.SetFailing(true)
+		_, err = client.ListCollections()
+		Expect(err).To(HaveOccurred())
+
+		openai := embeddings.NewOpenAIClientWithHTTP(server.URL, "", server.Client())
+		_, err = openai.EmbedQuery(context.Background(), "foo")
+		Expect(err).To(HaveOccurred())
+		Expect(buf.String()).To(BeEmpty())
+	})
+
+	It("logs embedding requests", func() {
+		openAI := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(`{"data": [{"embedding": [1, 2], "index": 0}, {"embedding": [3, 4], "index": 1}],
+				"model": "text-embedding-ada-002", "usage": {"prompt_tokens": 4, "total_tokens": 4}}`))
+		}))
+		defer openAI.Close()
+
+		openai := embeddings.NewOpenAIClientWithHTTP(openAI.URL, "", openAI.Client(),
+			embeddings.WithOpenAILogger(handler))
+		_, err := openai.EmbedDocuments(context.Background(), []string{"foo", "bar"})
+		Expect(err).ToNot(HaveOccurred())
+
+		lines := logLines(buf)
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(HaveKeyWithValue("provider", "openai"))
+		Expect(lines[0]).To(HaveKeyWithValue("path", "/embeddings"))
+		Expect(lines[0]).To(HaveKeyWithValue("batchSize", 2.0))
+		Expect(lines[0]).To(HaveKeyWithValue("status", 200.0))
+		Expect(lines[1]).To(HaveKeyWithValue("msg", "openai embedding token usage"))
+		Expect(lines[1]).To(HaveKeyWithValue("totalTokensUsed", 4.0))
+	})
+})
+
+var _ = Describe("Rate limiting", func() {
+	var server *httptest.Server
+	var unblock chan struct{}
+
+	BeforeEach(func() {
+		unblock = make(chan struct{})
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/api/v1/heartbeat":
+				<-unblock
+				rw.Write([]byte(`{"nanosecond heartbeat": 1}`))
+			case "/api/v1/collections/c1/count":
+				rw.Write([]byte("2"))
+			case "/api/v1/collections/c1/add":
+				rw.Write([]byte("true"))
+			default:
+				rw.Write([]byte(`{"id": "c1", "name": "limited", "metadata": {"hnsw:space": "l2"}}`))
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("limits the request rate of an operation class", func() {
+		client, err := chroma.NewClient(server.URL,
+			chroma.WithRateLimit(chroma.ReadOperations, chroma.Limits{RequestsPerSecond: 1, Burst: 1}))
+		Expect(err).ToNot(HaveOccurred())
+		collection, err := client.GetCollection("limited")
+		Expect(err).ToNot(HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = collection.CountContext(ctx)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		// writes are not limited
+		Expect(collection.Add([]chroma.Document{{ID: "1", Content: "a"}}, testEmbedder{})).To(Succeed())
+	})
+
+	It("caps requests in flight", func() {
+		client, err := chroma.NewClient(server.URL,
+			chroma.WithRateLimit(chroma.ReadOperations, chroma.Limits{MaxInFlight: 1}))
+		Expect(err).ToNot(HaveOccurred())
+		collection, err := client.GetCollection("limited")
+		Expect(err).ToNot(HaveOccurred())
+
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			_, err := client.Heartbeat()
+			Expect(err).ToNot(HaveOccurred())
+		}()
+		Eventually(func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err := collection.CountContext(ctx)
+			return err
+		}).Should(MatchError(context.DeadlineExceeded))
+
+		close(unblock)
+		<-done
+		Expect(collection.Count()).To(Equal(2))
+	})
+})
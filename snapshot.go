@@ -0,0 +1,193 @@
+package chroma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotManifestFile is the file of a snapshot directory listing its collections
+const SnapshotManifestFile = "manifest.json"
+
+// SnapshotManifest describes a snapshot written by Client.Snapshot
+type SnapshotManifest struct {
+	Version       int                  `json:"version"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	ServerVersion string               `json:"serverVersion"`
+	Collections   []SnapshotCollection `json:"collections"`
+}
+
+// SnapshotCollection is a collection of a snapshot, exported to File in the snapshot directory
+type SnapshotCollection struct {
+	ExportedCollection
+	File      string `json:"file"`
+	Documents int    `json:"documents"`
+}
+
+// Snapshot exports every collection to a gzipped JSON Lines file in dir, created if needed, and
+// writes the manifest listing them last. Collections written to while the snapshot runs may be
+// saved partially
+func (c *Client) Snapshot(ctx context.Context, dir string) (SnapshotManifest, error) {
+	manifest := SnapshotManifest{Version: ExportVersion, CreatedAt: time.Now().UTC()}
+	version, err := c.GetVersion()
+	if err != nil {
+		return manifest, err
+	}
+	manifest.ServerVersion = version
+	collections, err := c.ListCollections()
+	if err != nil {
+		return manifest, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return manifest, err
+	}
+	for _, collection := range collections {
+		saved := SnapshotCollection{
+			ExportedCollection: ExportedCollection{
+				Name: collection.Name, Metadata: collection.Metadata, DistanceFn: collection.DistanceFn,
+			},
+			File: collection.Name + ".jsonl.gz",
+		}
+		saved.Documents, err = exportFile(ctx, collection, filepath.Join(dir, saved.File))
+		if err != nil {
+			return manifest, fmt.Errorf("snapshot of collection %s: %w", collection.Name, err)
+		}
+		manifest.Collections = append(manifest.Collections, saved)
+	}
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	return manifest, os.WriteFile(filepath.Join(dir, SnapshotManifestFile), body, 0o644)
+}
+
+// exportFile exports collection to a gzipped file at path
+func exportFile(ctx context.Context, collection Collection, path string) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := collection.Export(ctx, f, ExportOptions{Gzip: true})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// ConflictPolicy decides what Client.Restore does with collections that already exist
+type ConflictPolicy int
+
+const (
+	// FailOnConflict fails the restore before restoring anything
+	FailOnConflict ConflictPolicy = iota
+	// SkipExisting leaves existing collections as they are
+	SkipExisting
+	// RenameOnConflict restores collections that exist under the name given by RestoreOptions.Rename
+	RenameOnConflict
+)
+
+// RestoreOptions configures Client.Restore
+type RestoreOptions struct {
+	OnConflict ConflictPolicy
+	// Rename returns the name of a collection restored under another name. Defaults to appending "-restored"
+	Rename func(name string) string
+	// BatchSize is the number of documents written per request. Defaults to 100
+	BatchSize int
+}
+
+// RestoredCollection is a collection of a snapshot handled by Client.Restore
+type RestoredCollection struct {
+	Source    string // name of the collection in the snapshot
+	Name      string // name of the restored collection
+	Documents int
+	Skipped   bool
+}
+
+// ErrCollectionExists is returned by Client.Restore with FailOnConflict when a collection of the
+// snapshot already exists
+var ErrCollectionExists = errors.New("collection already exists")
+
+// Restore recreates the collections of the snapshot in dir with their metadata and documents
+func (c *Client) Restore(ctx context.Context, dir string, opts RestoreOptions) ([]RestoredCollection, error) {
+	if opts.Rename == nil {
+		opts.Rename = func(name string) string { return name + "-restored" }
+	}
+	body, err := os.ReadFile(filepath.Join(dir, SnapshotManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var manifest SnapshotManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("reading snapshot manifest: %w", err)
+	}
+	if manifest.Version != ExportVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", manifest.Version)
+	}
+
+	existing, err := c.ListCollections()
+	if err != nil {
+		return nil, err
+	}
+	exists := map[string]bool{}
+	for _, collection := range existing {
+		exists[collection.Name] = true
+	}
+	// resolve every name first so conflicts fail before anything is restored
+	restored := make([]RestoredCollection, len(manifest.Collections))
+	for i, saved := range manifest.Collections {
+		restored[i] = RestoredCollection{Source: saved.Name, Name: saved.Name}
+		if !exists[saved.Name] {
+			continue
+		}
+		switch opts.OnConflict {
+		case SkipExisting:
+			restored[i].Skipped = true
+		case RenameOnConflict:
+			restored[i].Name = opts.Rename(saved.Name)
+			if exists[restored[i].Name] {
+				return nil, fmt.Errorf("%w: %s, renamed from %s", ErrCollectionExists, restored[i].Name, saved.Name)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrCollectionExists, saved.Name)
+		}
+	}
+
+	for i, saved := range manifest.Collections {
+		if restored[i].Skipped {
+			continue
+		}
+		n, err := c.restoreCollection(ctx, dir, saved, restored[i].Name, opts.BatchSize)
+		restored[i].Documents = n
+		if err != nil {
+			return restored[:i+1], fmt.Errorf("restoring collection %s: %w", saved.Name, err)
+		}
+	}
+	return restored, nil
+}
+
+// restoreCollection creates the collection called name and imports the documents of saved into it
+func (c *Client) restoreCollection(ctx context.Context, dir string, saved SnapshotCollection, name string,
+	batchSize int) (int, error) {
+	collection, err := c.createCollection(name, saved.DistanceFn, copyMetadata(saved.Metadata), false, nil)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Open(filepath.Join(dir, saved.File))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := collection.Import(ctx, f, ImportOptions{BatchSize: batchSize})
+	if err != nil {
+		return n, err
+	}
+	if n != saved.Documents {
+		return n, fmt.Errorf("snapshot file %s holds %d documents, the manifest %d", saved.File, n, saved.Documents)
+	}
+	return n, nil
+}
@@ -0,0 +1,97 @@
+package chroma_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	chroma "github.com/urjitbhatia/gochroma"
+)
+
+var _ = Describe("Snapshot", func() {
+	var server *fakeChroma
+	var client *chroma.Client
+	var dir string
+
+	BeforeEach(func() {
+		server = newFakeChroma()
+		c, err := chroma.NewClient(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		client = c.(*chroma.Client)
+		dir = filepath.Join(GinkgoT().TempDir(), "snapshot")
+
+		articles, err := client.CreateCollection("articles", "cosine", map[string]any{"team": "search"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(articles.Add([]chroma.Document{
+			{ID: "a1", Content: "first", Metadata: map[string]any{"lang": "en"}, Embeddings: []float32{1, 0}},
+			{ID: "a2", Content: "second", Embeddings: []float32{0, 1}},
+		}, nil)).To(Succeed())
+		_, err = client.CreateCollection("empty", "l2", nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("saves every collection with a manifest and restores them", func() {
+		manifest, err := client.Snapshot(context.Background(), dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest.ServerVersion).To(Equal("0.4.14"))
+		Expect(manifest.Collections).To(HaveLen(2))
+		Expect(manifest.Collections[0].Name).To(Equal("articles"))
+		Expect(manifest.Collections[0].Documents).To(Equal(2))
+		Expect(manifest.Collections[1].Documents).To(Equal(0))
+		for _, file := range []string{chroma.SnapshotManifestFile, "articles.jsonl.gz", "empty.jsonl.gz"} {
+			Expect(filepath.Join(dir, file)).To(BeAnExistingFile())
+		}
+
+		Expect(client.Reset()).To(BeTrue())
+		restored, err := client.Restore(context.Background(), dir, chroma.RestoreOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored).To(Equal([]chroma.RestoredCollection{
+			{Source: "articles", Name: "articles", Documents: 2},
+			{Source: "empty", Name: "empty"},
+		}))
+
+		articles, err := client.GetCollection("articles")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(articles.DistanceFn).To(Equal("cosine"))
+		Expect(articles.Metadata).To(HaveKeyWithValue("team", "search"))
+		Expect(server.byName("articles").docs).To(Equal([]fakeDoc{
+			{ID: "a1", Document: "first", Metadata: map[string]any{"lang": "en"}, Embedding: []float32{1, 0}},
+			{ID: "a2", Document: "second", Embedding: []float32{0, 1}},
+		}))
+	})
+
+	It("fails, skips or renames collections that exist", func() {
+		_, err := client.Snapshot(context.Background(), dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.DeleteCollection("empty")).To(Succeed())
+
+		_, err = client.Restore(context.Background(), dir, chroma.RestoreOptions{})
+		Expect(err).To(MatchError(chroma.ErrCollectionExists))
+		Expect(server.byName("empty")).To(BeNil())
+
+		restored, err := client.Restore(context.Background(), dir, chroma.RestoreOptions{OnConflict: chroma.SkipExisting})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored).To(Equal([]chroma.RestoredCollection{
+			{Source: "articles", Name: "articles", Skipped: true},
+			{Source: "empty", Name: "empty"},
+		}))
+
+		restored, err = client.Restore(context.Background(), dir, chroma.RestoreOptions{OnConflict: chroma.RenameOnConflict})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored[0]).To(Equal(chroma.RestoredCollection{Source: "articles", Name: "articles-restored", Documents: 2}))
+		Expect(server.byName("articles-restored").docs).To(HaveLen(2))
+		Expect(server.byName("articles").docs).To(HaveLen(2))
+	})
+
+	It("fails without a manifest", func() {
+		Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+		_, err := client.Restore(context.Background(), dir, chroma.RestoreOptions{})
+		Expect(err).To(MatchError(os.ErrNotExist))
+	})
+})